@@ -0,0 +1,351 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// DurationAssert は応答時間の上限です。max はボディの読み込みまでを含む全体、ttfb は最初のバイトまでの時間に適用します。
+// 0 の場合は検証しません
+type DurationAssert struct {
+	Max  time.Duration `yaml:"max"`
+	TTFB time.Duration `yaml:"ttfb"`
+}
+
+// HeaderAssert はレスポンスヘッダーを検証します。
+// 同じ名前のヘッダーが複数ある場合は、いずれかの値が equals・regex を満たせば成功です
+type HeaderAssert struct {
+	Name   string `yaml:"name"`
+	Exists *bool  `yaml:"exists"`
+	Equals string `yaml:"equals"`
+	Regex  string `yaml:"regex"`
+}
+
+// JSONAssert はレスポンスボディの JSON のうち path が指す値を検証します。
+// 指定したマッチャーはすべて満たす必要があります
+type JSONAssert struct {
+	Path      string      `yaml:"path"`
+	Equals    interface{} `yaml:"equals"`
+	NotEquals interface{} `yaml:"not_equals"`
+	Exists    *bool       `yaml:"exists"`
+	Regex     string      `yaml:"regex"`
+	Gt        *float64    `yaml:"gt"`
+	Lt        *float64    `yaml:"lt"`
+	Length    *int        `yaml:"length"`
+}
+
+// validate は設定時点でアサートの path・ヘッダー名・正規表現を検証します
+func (a *AssertsConfig) validate() error {
+	if err := a.Duration.validate(); err != nil {
+		return err
+	}
+	if err := a.TLS.validate(); err != nil {
+		return err
+	}
+	if err := validateProtocolAssert(a.Protocol); err != nil {
+		return err
+	}
+	for i, assert := range a.Headers {
+		if err := assert.validate(); err != nil {
+			return fmt.Errorf("asserts.headers[%d]: %w", i, err)
+		}
+	}
+	for i, assert := range a.JSON {
+		if err := assert.validate(); err != nil {
+			return fmt.Errorf("asserts.json[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (a *DurationAssert) validate() error {
+	if a.Max < 0 || a.TTFB < 0 {
+		return fmt.Errorf("asserts.duration must not be negative")
+	}
+	return nil
+}
+
+// check は上限を超えたフェーズと実際の時間を含むエラーを返します
+func (a *DurationAssert) check(result *Result) error {
+	if a.TTFB > 0 && result.Timings.TTFB > a.TTFB {
+		return fmt.Errorf("ttfb %v exceeds %v", result.Timings.TTFB, a.TTFB)
+	}
+	if a.Max > 0 && result.Duration > a.Max {
+		return fmt.Errorf("duration %v exceeds %v", result.Duration, a.Max)
+	}
+	return nil
+}
+
+func (a *HeaderAssert) validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if a.Regex != "" {
+		if _, err := regexp.Compile(a.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	if a.Exists == nil && a.Equals == "" && a.Regex == "" {
+		return fmt.Errorf("no matcher for %s", a.Name)
+	}
+	if a.Exists != nil && !*a.Exists && (a.Equals != "" || a.Regex != "") {
+		return fmt.Errorf("exists: false cannot be combined with equals or regex")
+	}
+	return nil
+}
+
+// check は失敗した場合にヘッダー名・期待値・実際の値を含むエラーを返します
+func (a *HeaderAssert) check(header http.Header) error {
+	values := header.Values(a.Name)
+
+	if a.Exists != nil && !*a.Exists {
+		if len(values) > 0 {
+			return fmt.Errorf("header %s: expected not to exist, got %s", a.Name, formatJSONValue(values))
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("header %s: expected %s, got <missing>", a.Name, a.expectation())
+	}
+
+	if a.Equals != "" && !containsString(values, a.Equals) {
+		return fmt.Errorf("header %s: expected %s, got %s", a.Name, formatJSONValue(a.Equals), formatJSONValue(values))
+	}
+	if a.Regex != "" {
+		re, err := regexp.Compile(a.Regex)
+		if err != nil {
+			return fmt.Errorf("header %s: invalid regex: %w", a.Name, err)
+		}
+		matched := false
+		for _, value := range values {
+			if re.MatchString(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("header %s: expected to match regex %s, got %s", a.Name, a.Regex, formatJSONValue(values))
+		}
+	}
+	return nil
+}
+
+func (a *HeaderAssert) expectation() string {
+	switch {
+	case a.Equals != "":
+		return formatJSONValue(a.Equals)
+	case a.Regex != "":
+		return "to match regex " + a.Regex
+	}
+	return "to exist"
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeaderAsserts はすべてのヘッダーの検証を行い、最初に失敗したものを返します
+func validateHeaderAsserts(asserts []HeaderAssert, header http.Header) error {
+	for i := range asserts {
+		if err := asserts[i].check(header); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *JSONAssert) validate() error {
+	if a.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if _, err := parseJSONPath(a.Path); err != nil {
+		return err
+	}
+	if a.Regex != "" {
+		if _, err := regexp.Compile(a.Regex); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	if a.Equals == nil && a.NotEquals == nil && a.Exists == nil && a.Regex == "" && a.Gt == nil && a.Lt == nil && a.Length == nil {
+		return fmt.Errorf("no matcher for %s", a.Path)
+	}
+	return nil
+}
+
+// validateJSONAsserts はボディを JSON としてデコードし、各 path の値を検証します
+func validateJSONAsserts(asserts []JSONAssert, body []byte) error {
+	data, err := decodeJSONBody(body)
+	if err != nil {
+		return err
+	}
+	for i := range asserts {
+		if err := asserts[i].check(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// check は失敗した場合に path・期待値・実際の値を含むエラーを返します
+func (a *JSONAssert) check(data interface{}) error {
+	actual, found, err := lookupJSONPath(data, a.Path)
+	if err != nil {
+		return err
+	}
+
+	if a.Exists != nil {
+		if found != *a.Exists {
+			if found {
+				return fmt.Errorf("json %s: expected not to exist, got %s", a.Path, formatJSONValue(actual))
+			}
+			return fmt.Errorf("json %s: expected to exist, got <missing>", a.Path)
+		}
+		if !found {
+			return nil
+		}
+	}
+	if !found {
+		return fmt.Errorf("json %s: expected %s, got <missing>", a.Path, a.expectation())
+	}
+
+	if a.Equals != nil {
+		expected, err := normalizeJSONValue(a.Equals)
+		if err != nil {
+			return fmt.Errorf("json %s: %w", a.Path, err)
+		}
+		if !jsonValuesEqual(expected, actual) {
+			return fmt.Errorf("json %s: expected %s, got %s", a.Path, formatJSONValue(expected), formatJSONValue(actual))
+		}
+	}
+	if a.NotEquals != nil {
+		expected, err := normalizeJSONValue(a.NotEquals)
+		if err != nil {
+			return fmt.Errorf("json %s: %w", a.Path, err)
+		}
+		if jsonValuesEqual(expected, actual) {
+			return fmt.Errorf("json %s: expected not %s, got %s", a.Path, formatJSONValue(expected), formatJSONValue(actual))
+		}
+	}
+	if a.Regex != "" {
+		re, err := regexp.Compile(a.Regex)
+		if err != nil {
+			return fmt.Errorf("json %s: invalid regex: %w", a.Path, err)
+		}
+		if !re.MatchString(jsonValueString(actual)) {
+			return fmt.Errorf("json %s: expected to match regex %s, got %s", a.Path, a.Regex, formatJSONValue(actual))
+		}
+	}
+	if a.Gt != nil || a.Lt != nil {
+		number, ok := actual.(json.Number)
+		if !ok {
+			return fmt.Errorf("json %s: expected a number, got %s", a.Path, formatJSONValue(actual))
+		}
+		value, err := number.Float64()
+		if err != nil {
+			return fmt.Errorf("json %s: %w", a.Path, err)
+		}
+		if a.Gt != nil && !(value > *a.Gt) {
+			return fmt.Errorf("json %s: expected > %v, got %s", a.Path, *a.Gt, number)
+		}
+		if a.Lt != nil && !(value < *a.Lt) {
+			return fmt.Errorf("json %s: expected < %v, got %s", a.Path, *a.Lt, number)
+		}
+	}
+	if a.Length != nil {
+		array, ok := actual.([]interface{})
+		if !ok {
+			return fmt.Errorf("json %s: expected an array, got %s", a.Path, formatJSONValue(actual))
+		}
+		if len(array) != *a.Length {
+			return fmt.Errorf("json %s: expected length %d, got %d", a.Path, *a.Length, len(array))
+		}
+	}
+	return nil
+}
+
+// expectation は値が見つからなかった場合のメッセージに使う期待値の表記です
+func (a *JSONAssert) expectation() string {
+	switch {
+	case a.Equals != nil:
+		if expected, err := normalizeJSONValue(a.Equals); err == nil {
+			return formatJSONValue(expected)
+		}
+	case a.Regex != "":
+		return "to match regex " + a.Regex
+	case a.Gt != nil:
+		return fmt.Sprintf("> %v", *a.Gt)
+	case a.Lt != nil:
+		return fmt.Sprintf("< %v", *a.Lt)
+	case a.Length != nil:
+		return fmt.Sprintf("length %d", *a.Length)
+	}
+	return "a value"
+}
+
+// normalizeJSONValue は YAML で書かれた期待値を、レスポンスと同じく json.Number を使う形に変換します
+func normalizeJSONValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected value: %w", err)
+	}
+	return decodeJSONBody(data)
+}
+
+// jsonValuesEqual は値を比較します。数値は表記ではなく値で比較するため 1 と 1.0 は等しくなります
+func jsonValuesEqual(a, b interface{}) bool {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		if a == b {
+			return true
+		}
+		af, aErr := a.Float64()
+		bf, bErr := b.Float64()
+		return aErr == nil && bErr == nil && af == bf
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, av := range a {
+			bv, ok := b[k]
+			if !ok || !jsonValuesEqual(av, bv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !jsonValuesEqual(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// formatJSONValue はエラーメッセージ向けに値を JSON で表記します
+func formatJSONValue(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
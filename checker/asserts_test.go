@@ -0,0 +1,339 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateJSONAsserts(t *testing.T) {
+	content := `url: https://example.com
+asserts:
+  json:
+    - path: $.status
+      equals: ok
+    - path: $.db.healthy
+      equals: true
+    - path: $.db.latency_ms
+      gt: 0
+      lt: 100
+    - path: $.version
+      regex: ^1\.
+    - path: $.replicas
+      length: 2
+    - path: $.replicas[0]
+      equals: {name: a, lag: 0.0}
+    - path: $.status
+      not_equals: degraded
+    - path: $.error
+      exists: false
+    - path: $.db
+      exists: true
+`
+	tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	resp := &http.Response{StatusCode: 200}
+	body := []byte(`{"status":"ok","version":"1.4.2","db":{"healthy":true,"latency_ms":12},"replicas":[{"name":"a","lag":0},{"name":"b","lag":3}]}`)
+	if err := validateResponse(config, resp, body); err != nil {
+		t.Errorf("validateResponse() error = %v", err)
+	}
+}
+
+func TestValidateJSONAssertsFailure(t *testing.T) {
+	body := []byte(`{"status":"degraded","count":"3","db":{"healthy":false,"latency_ms":250},"items":[1,2,3]}`)
+	yes, no := true, false
+	limit, floor := 100.0, 300.0
+	two := 2
+
+	tests := []struct {
+		name   string
+		assert JSONAssert
+		want   string
+	}{
+		{
+			name:   "equals",
+			assert: JSONAssert{Path: "$.status", Equals: "ok"},
+			want:   `json $.status: expected "ok", got "degraded"`,
+		},
+		{
+			name:   "equals bool",
+			assert: JSONAssert{Path: "$.db.healthy", Equals: true},
+			want:   `json $.db.healthy: expected true, got false`,
+		},
+		{
+			name:   "string is not a number",
+			assert: JSONAssert{Path: "$.count", Equals: 3},
+			want:   `json $.count: expected 3, got "3"`,
+		},
+		{
+			name:   "not equals",
+			assert: JSONAssert{Path: "$.status", NotEquals: "degraded"},
+			want:   `json $.status: expected not "degraded", got "degraded"`,
+		},
+		{
+			name:   "missing",
+			assert: JSONAssert{Path: "$.db.version", Equals: "1"},
+			want:   `json $.db.version: expected "1", got <missing>`,
+		},
+		{
+			name:   "exists",
+			assert: JSONAssert{Path: "$.error", Exists: &yes},
+			want:   `json $.error: expected to exist, got <missing>`,
+		},
+		{
+			name:   "not exists",
+			assert: JSONAssert{Path: "$.db.healthy", Exists: &no},
+			want:   `json $.db.healthy: expected not to exist, got false`,
+		},
+		{
+			name:   "regex",
+			assert: JSONAssert{Path: "$.status", Regex: "^ok$"},
+			want:   `json $.status: expected to match regex ^ok$, got "degraded"`,
+		},
+		{
+			name:   "lt",
+			assert: JSONAssert{Path: "$.db.latency_ms", Lt: &limit},
+			want:   `json $.db.latency_ms: expected < 100, got 250`,
+		},
+		{
+			name:   "gt",
+			assert: JSONAssert{Path: "$.db.latency_ms", Gt: &floor},
+			want:   `json $.db.latency_ms: expected > 300, got 250`,
+		},
+		{
+			name:   "gt on string",
+			assert: JSONAssert{Path: "$.count", Gt: &limit},
+			want:   `json $.count: expected a number, got "3"`,
+		},
+		{
+			name:   "length",
+			assert: JSONAssert{Path: "$.items", Length: &two},
+			want:   `json $.items: expected length 2, got 3`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateJSONAsserts([]JSONAssert{tt.assert}, body)
+			if err == nil {
+				t.Fatalf("validateJSONAsserts() expected error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateJSONAssertsInvalidBody(t *testing.T) {
+	err := validateJSONAsserts([]JSONAssert{{Path: "$.status", Equals: "ok"}}, []byte("<html>"))
+	if err == nil || !strings.Contains(err.Error(), "not valid JSON") {
+		t.Errorf("validateJSONAsserts() error = %v, want invalid JSON error", err)
+	}
+}
+
+func TestJSONAssertValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		assert JSONAssert
+	}{
+		{name: "missing path", assert: JSONAssert{Equals: "ok"}},
+		{name: "no matcher", assert: JSONAssert{Path: "$.status"}},
+		{name: "invalid regex", assert: JSONAssert{Path: "$.status", Regex: "("}},
+		{name: "invalid path", assert: JSONAssert{Path: "$.a[0", Equals: "ok"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.assert.validate(); err == nil {
+				t.Errorf("validate() expected error")
+			}
+		})
+	}
+}
+
+func TestValidateHeaderAsserts(t *testing.T) {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Cache-Control", "private, no-store")
+	header.Set("Strict-Transport-Security", "max-age=63072000")
+	header.Add("X-Served-By", "cache-1")
+	header.Add("X-Served-By", "web-12")
+	yes, no := true, false
+
+	tests := []struct {
+		name   string
+		assert HeaderAssert
+		want   string
+	}{
+		{name: "equals", assert: HeaderAssert{Name: "content-type", Equals: "application/json"}},
+		{name: "regex", assert: HeaderAssert{Name: "Cache-Control", Regex: `\bno-store\b`}},
+		{name: "exists", assert: HeaderAssert{Name: "Strict-Transport-Security", Exists: &yes}},
+		{name: "not exists", assert: HeaderAssert{Name: "Server", Exists: &no}},
+		{name: "any of multiple values", assert: HeaderAssert{Name: "X-Served-By", Regex: `^web-\d+$`}},
+		{
+			name:   "equals mismatch",
+			assert: HeaderAssert{Name: "Content-Type", Equals: "text/html"},
+			want:   `header Content-Type: expected "text/html", got ["application/json"]`,
+		},
+		{
+			name:   "regex mismatch on multiple values",
+			assert: HeaderAssert{Name: "X-Served-By", Regex: `^app-`},
+			want:   `header X-Served-By: expected to match regex ^app-, got ["cache-1","web-12"]`,
+		},
+		{
+			name:   "missing",
+			assert: HeaderAssert{Name: "X-Frame-Options", Exists: &yes},
+			want:   `header X-Frame-Options: expected to exist, got <missing>`,
+		},
+		{
+			name:   "missing with equals",
+			assert: HeaderAssert{Name: "X-Frame-Options", Equals: "DENY"},
+			want:   `header X-Frame-Options: expected "DENY", got <missing>`,
+		},
+		{
+			name:   "must not be present",
+			assert: HeaderAssert{Name: "Strict-Transport-Security", Exists: &no},
+			want:   `header Strict-Transport-Security: expected not to exist, got ["max-age=63072000"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateHeaderAsserts([]HeaderAssert{tt.assert}, header)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("validateHeaderAsserts() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("validateHeaderAsserts() expected error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderAssertValidate(t *testing.T) {
+	no := false
+	tests := []struct {
+		name   string
+		assert HeaderAssert
+	}{
+		{name: "missing name", assert: HeaderAssert{Equals: "x"}},
+		{name: "no matcher", assert: HeaderAssert{Name: "Server"}},
+		{name: "invalid regex", assert: HeaderAssert{Name: "Server", Regex: "("}},
+		{name: "absent with value", assert: HeaderAssert{Name: "Server", Exists: &no, Equals: "nginx"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.assert.validate(); err == nil {
+				t.Errorf("validate() expected error")
+			}
+		})
+	}
+}
+
+func TestDurationAssert(t *testing.T) {
+	result := &Result{
+		Duration: 900 * time.Millisecond,
+		Timings:  Timings{TTFB: 600 * time.Millisecond},
+	}
+
+	tests := []struct {
+		name   string
+		assert DurationAssert
+		want   string
+	}{
+		{name: "disabled", assert: DurationAssert{}},
+		{name: "within limits", assert: DurationAssert{Max: time.Second, TTFB: time.Second}},
+		{name: "max", assert: DurationAssert{Max: 500 * time.Millisecond}, want: "duration 900ms exceeds 500ms"},
+		{name: "ttfb", assert: DurationAssert{Max: time.Second, TTFB: 200 * time.Millisecond}, want: "ttfb 600ms exceeds 200ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.assert.check(result)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("check() error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("check() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlowResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wrong" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path       string
+		wantStatus int
+	}{
+		{path: "/", wantStatus: StatusSlowResponse},
+		// 内容の誤りは遅延よりも優先して報告します
+		{path: "/wrong", wantStatus: StatusAssertFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			config := &Config{
+				URL:     server.URL + tt.path,
+				Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{Values: []int{200}},
+					Duration:   DurationAssert{Max: 50 * time.Millisecond},
+				},
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Fatalf("statusCode = %d, want %d", result.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != StatusSlowResponse {
+				return
+			}
+			if result.AssertErr != nil {
+				t.Errorf("assertErr = %v, want nil", result.AssertErr)
+			}
+			if result.Err == nil || !strings.Contains(result.Err.Error(), "exceeds 50ms") {
+				t.Errorf("err = %v, want duration breach", result.Err)
+			}
+			if got := config.newJSONRecord(result); got.Error != "SLOW_RESPONSE" || got.AssertFailure != "" {
+				t.Errorf("record = %+v", got)
+			}
+		})
+	}
+}
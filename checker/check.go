@@ -0,0 +1,383 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// エラーコードの定義
+const (
+	StatusDNSLookupFailed       = -1
+	StatusConnectionFailed      = -2
+	StatusTimeout               = -3
+	StatusRedirectLoop          = -4
+	StatusAssertFailed          = -5
+	StatusConnectTimeout        = -6
+	StatusTLSHandshakeTimeout   = -7
+	StatusResponseHeaderTimeout = -8
+	StatusReadTimeout           = -9
+	StatusTLSHandshakeFailed    = -10
+	StatusCertificateInvalid    = -11
+	StatusConnectionReset       = -12
+	StatusEmptyResponse         = -13
+	StatusProxyError            = -14
+	StatusSlowResponse          = -15
+	StatusUnknown               = -999
+)
+
+// エラーメッセージの定義
+var errorMessages = map[int]string{
+	StatusDNSLookupFailed:       "DNS_LOOKUP_FAILED",
+	StatusConnectionFailed:      "CONNECTION_FAILED",
+	StatusTimeout:               "TIMEOUT",
+	StatusRedirectLoop:          "REDIRECT_LOOP_DETECTED",
+	StatusAssertFailed:          "ASSERT_FAILED",
+	StatusConnectTimeout:        "CONNECT_TIMEOUT",
+	StatusTLSHandshakeTimeout:   "TLS_HANDSHAKE_TIMEOUT",
+	StatusResponseHeaderTimeout: "RESPONSE_HEADER_TIMEOUT",
+	StatusReadTimeout:           "READ_TIMEOUT",
+	StatusTLSHandshakeFailed:    "TLS_HANDSHAKE_FAILED",
+	StatusCertificateInvalid:    "CERTIFICATE_INVALID",
+	StatusConnectionReset:       "CONNECTION_RESET",
+	StatusEmptyResponse:         "EMPTY_RESPONSE",
+	StatusProxyError:            "PROXY_ERROR",
+	StatusSlowResponse:          "SLOW_RESPONSE",
+	StatusUnknown:               "UNKNOWN_ERROR",
+}
+
+// errTooManyRedirects は CheckRedirect がリダイレクト上限に達したときに返すエラーです
+var errTooManyRedirects = errors.New("too many redirects")
+
+// proxyError はプロキシが CONNECT、または HTTP のターゲットへのリクエストを拒否したことを表します
+type proxyError struct {
+	proxyURL *url.URL
+	method   string
+	status   string
+}
+
+func (e *proxyError) Error() string {
+	return fmt.Sprintf("proxy %s refused %s: %s", e.proxyURL.Redacted(), e.method, e.status)
+}
+
+func getErrorStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var pxErr *proxyError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	var certVerifyErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certInvalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.Is(err, errTooManyRedirects):
+		return StatusRedirectLoop
+	case errors.As(err, &pxErr), errors.As(err, &opErr) && (opErr.Op == "proxyconnect" || opErr.Op == "socks connect"):
+		return StatusProxyError
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsTimeout {
+			return StatusTimeout
+		}
+		return StatusDNSLookupFailed
+	case isTimeout(err):
+		return StatusTimeout
+	case errors.As(err, &certVerifyErr), errors.As(err, &unknownAuthorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &certInvalidErr):
+		return StatusCertificateInvalid
+	case errors.As(err, &recordErr), errors.As(err, &alertErr),
+		errors.As(err, &opErr) && opErr.Op == "remote error":
+		// TLS 1.3 ではクライアント証明書の拒否がハンドシェイク後にアラートとして届きます
+		return StatusTLSHandshakeFailed
+	case errors.Is(err, syscall.ECONNRESET):
+		return StatusConnectionReset
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return StatusEmptyResponse
+	case errors.Is(err, syscall.ECONNREFUSED), errors.As(err, &opErr) && opErr.Op == "dial":
+		return StatusConnectionFailed
+	default:
+		return StatusUnknown
+	}
+}
+
+func validateResponse(config *Config, resp *http.Response, body []byte) error {
+	// ステータスコードの検証
+	if len(config.Asserts.StatusCode.Values) > 0 {
+		found := false
+		for _, code := range config.Asserts.StatusCode.Values {
+			if resp.StatusCode == code {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("status code %d not in expected values %v", resp.StatusCode, config.Asserts.StatusCode.Values)
+		}
+	}
+
+	if config.Asserts.StatusCode.Regex != "" {
+		re, err := regexp.Compile(config.Asserts.StatusCode.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid status code regex: %w", err)
+		}
+		if !re.MatchString(strconv.Itoa(resp.StatusCode)) {
+			return fmt.Errorf("status code %d does not match regex %s", resp.StatusCode, config.Asserts.StatusCode.Regex)
+		}
+	}
+
+	// TLS 接続と証明書の検証
+	if err := config.Asserts.TLS.check(resp, time.Now()); err != nil {
+		return err
+	}
+
+	// ネゴシエートされたプロトコルの検証
+	if err := checkProtocol(config.Asserts.Protocol, resp); err != nil {
+		return err
+	}
+
+	// レスポンスヘッダーの検証
+	if err := validateHeaderAsserts(config.Asserts.Headers, resp.Header); err != nil {
+		return err
+	}
+
+	// レスポンスボディの検証
+	if config.Asserts.Body.Regex != "" {
+		re, err := regexp.Compile(config.Asserts.Body.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid body regex: %w", err)
+		}
+		if !re.Match(body) {
+			return fmt.Errorf("body does not match regex %s", config.Asserts.Body.Regex)
+		}
+	}
+
+	if len(config.Asserts.JSON) > 0 {
+		if err := validateJSONAsserts(config.Asserts.JSON, body); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newClient はターゲット用の HTTP クライアントを作成します。jar が nil の場合はメモリ上の jar を使います
+func newClient(config *Config, jar http.CookieJar) (*http.Client, error) {
+	if jar == nil {
+		memoryJar, err := cookiejar.New(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+		}
+		jar = memoryJar
+	}
+
+	tlsConfig, err := config.TLS.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+	proxy, err := config.Proxy.proxyFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := config.newDialer()
+
+	client := &http.Client{
+		Jar: jar,
+		Transport: &http.Transport{
+			Proxy:                 proxy,
+			DialContext:           dialer.DialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   config.Timeout.tlsHandshake(),
+			ResponseHeaderTimeout: config.Timeout.Read,
+			DisableKeepAlives:     !config.Connection.KeepAlive,
+			IdleConnTimeout:       config.Connection.IdleTimeout,
+			MaxIdleConns:          config.Connection.MaxIdle,
+			MaxIdleConnsPerHost:   config.Connection.MaxIdle,
+			Protocols:             config.protocols(),
+			OnProxyConnectResponse: func(ctx context.Context, proxyURL *url.URL, connectReq *http.Request, connectRes *http.Response) error {
+				if connectRes.StatusCode != http.StatusOK {
+					return &proxyError{proxyURL: proxyURL, method: http.MethodConnect, status: connectRes.Status}
+				}
+				return nil
+			},
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !config.FollowRedirects.Enabled {
+				return http.ErrUseLastResponse
+			}
+			if len(via) >= config.FollowRedirects.MaxCount {
+				return fmt.Errorf("stopped after %d redirects: %w", config.FollowRedirects.MaxCount, errTooManyRedirects)
+			}
+			return nil
+		},
+	}
+
+	if err := config.SetupCookies(jar); err != nil {
+		return nil, fmt.Errorf("failed to setup cookies: %w", err)
+	}
+
+	return client, nil
+}
+
+// Result は1回のチェック結果です
+type Result struct {
+	// Target はターゲット名です。name が無い場合は URL になります
+	Target      string
+	RequestedAt time.Time
+	// StatusCode は成功した場合は HTTP ステータスコード、失敗した場合は Status から始まる負の値です
+	StatusCode int
+	Duration   time.Duration
+	Timings    Timings
+	// URL はリダイレクトをたどった後、最後にリクエストした URL です
+	URL string
+	// Err はリクエストの失敗や応答時間の超過の理由です
+	Err error
+	// AssertErr は満たさなかったアサートです
+	AssertErr error
+	// Response はレスポンスを受信した場合のみ設定されます。ボディは読み込み済みで Body に入っています
+	Response *http.Response
+	Body     []byte
+	// Steps と FailedStep はシナリオの場合のみ設定されます
+	Steps      []StepResult
+	FailedStep string
+	// TLS は HTTPS の場合のみ設定されます
+	TLS      *TLSInfo
+	RemoteIP string
+	// IP は fan_out: all_ips でチェックした IP アドレスです
+	IP     string
+	Reused bool
+	Proto  string
+}
+
+// checkOnce は1回分のチェックを行います。steps が設定されている場合はシナリオ全体を実行します
+func checkOnce(ctx context.Context, config *Config, client *http.Client) (*Result, error) {
+	if len(config.Steps) > 0 {
+		return checkScenario(ctx, config, client)
+	}
+	return checkRequest(ctx, config, client)
+}
+
+// checkRequest はリクエストを1回送信し、レスポンスを検証します。
+// 接続・TLSハンドシェイク・レスポンスヘッダー・ボディ読み込みの各フェーズに個別のタイムアウトを適用します
+func checkRequest(ctx context.Context, config *Config, client *http.Client) (*Result, error) {
+	result := &Result{
+		Target:      config.targetName(),
+		RequestedAt: time.Now(),
+		URL:         config.URL,
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	trace := &requestTrace{}
+	req, err := config.NewRequest(trace.withContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Duration = time.Since(start)
+		result.Timings = trace.timings()
+		result.RemoteIP = trace.remoteIP()
+		result.Reused = trace.reused()
+		result.Err = err
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			result.URL = urlErr.URL
+		}
+		result.StatusCode = getRequestErrorStatus(err, trace.currentPhase())
+		return result, nil
+	}
+
+	// ボディの読み込みはレスポンスヘッダー受信後から read タイムアウトで打ち切ります。
+	// ResponseHeaderTimeout と同様に 0 の場合は打ち切りません
+	var readTimedOut atomic.Bool
+	if config.Timeout.Read > 0 {
+		timer := time.AfterFunc(config.Timeout.Read, func() {
+			readTimedOut.Store(true)
+			cancel()
+		})
+		defer timer.Stop()
+	}
+	body, err := io.ReadAll(resp.Body)
+	trace.finishBody()
+	resp.Body.Close()
+	result.Duration = time.Since(start)
+	result.Timings = trace.timings()
+	result.RemoteIP = trace.remoteIP()
+	result.Reused = trace.reused()
+	result.URL = resp.Request.URL.String()
+	result.Response = resp
+	result.Body = body
+	result.Proto = resp.Proto
+	if resp.TLS != nil {
+		result.TLS = newTLSInfo(resp.TLS, result.RequestedAt)
+	}
+
+	if err != nil {
+		result.Err = err
+		if readTimedOut.Load() {
+			result.StatusCode = StatusReadTimeout
+		} else {
+			result.StatusCode = getRequestErrorStatus(err, phaseBodyRead)
+		}
+		return result, nil
+	}
+
+	// HTTP のターゲットへのリクエストはプロキシがそのまま応答するため、プロキシの認証の失敗は CONNECT と同じく PROXY_ERROR とします
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		if proxyURL := config.Proxy.forwardingProxy(resp.Request); proxyURL != nil {
+			result.Err = &proxyError{proxyURL: proxyURL, method: resp.Request.Method, status: resp.Status}
+			result.StatusCode = StatusProxyError
+			return result, nil
+		}
+	}
+
+	if err := validateResponse(config, resp, body); err != nil {
+		result.AssertErr = err
+		result.StatusCode = StatusAssertFailed
+		return result, nil
+	}
+
+	// 内容が正しくても応答時間の上限を超えた場合は ASSERT_FAILED と区別して SLOW_RESPONSE とします
+	if err := config.Asserts.Duration.check(result); err != nil {
+		result.Err = err
+		result.StatusCode = StatusSlowResponse
+		return result, nil
+	}
+
+	result.StatusCode = resp.StatusCode
+	return result, nil
+}
+
+// Success はリクエストが成功し、すべてのアサートを満たしたかどうかを返します
+func (r *Result) Success() bool {
+	return r.StatusCode > 0
+}
+
+// StatusText は標準出力向けのステータス表記を返します
+func (r *Result) StatusText() string {
+	if r.StatusCode < 0 {
+		return errorMessages[r.StatusCode]
+	}
+	return strconv.Itoa(r.StatusCode)
+}
@@ -0,0 +1,914 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBasicRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond) // Wait for 2-3 requests
+	defer cancel()
+
+	if err := runChecker(t, ctx, config); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Second)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 100 * time.Millisecond,
+			Read:    100 * time.Millisecond,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	if err := runChecker(t, ctx, config); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestCookies(t *testing.T) {
+	expectedCookie := "test-cookie"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie := r.Header.Get("Cookie")
+		if !strings.Contains(cookie, expectedCookie) {
+			t.Errorf("Expected cookie %s, got %s", expectedCookie, cookie)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Cookies: []CookieConfig{
+			{
+				Key:   "session",
+				Value: expectedCookie,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	if err := runChecker(t, ctx, config); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "test.log")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Log: &LogConfig{
+			Path:   logPath,
+			Format: "{{.statusCode}}",
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	if err := runChecker(t, ctx, config, NewLogSink(os.Stderr)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// Check if log file exists and contains correct status code
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Errorf("Failed to read log file: %v", err)
+	}
+
+	if !strings.Contains(string(content), "200") {
+		t.Errorf("Expected log to contain status code 200, got %s", string(content))
+	}
+}
+
+func TestConfigValidation(t *testing.T) {
+	tempFile := filepath.Join(t.TempDir(), "config.yaml")
+	content := []byte(`url: http://example.com
+interval: 1s
+timeout:
+  connect: 3s
+  read: 7s`)
+
+	if err := os.WriteFile(tempFile, content, 0644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	config, err := LoadConfig(tempFile)
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	if config.URL != "http://example.com" {
+		t.Errorf("Expected URL http://example.com, got %s", config.URL)
+	}
+
+	if config.Interval != time.Second {
+		t.Errorf("Expected interval 1s, got %v", config.Interval)
+	}
+
+	if config.Timeout.Connect != 3*time.Second {
+		t.Errorf("Expected connect timeout 3s, got %v", config.Timeout.Connect)
+	}
+
+	if config.Timeout.Read != 7*time.Second {
+		t.Errorf("Expected read timeout 7s, got %v", config.Timeout.Read)
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected int
+	}{
+		{
+			name:     "DNS lookup failed",
+			err:      &url.Error{Op: "Get", URL: "http://example.invalid", Err: &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}}},
+			expected: StatusDNSLookupFailed,
+		},
+		{
+			name:     "Connection refused",
+			err:      &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expected: StatusConnectionFailed,
+		},
+		{
+			name:     "Timeout",
+			err:      fmt.Errorf("request failed: %w", context.DeadlineExceeded),
+			expected: StatusTimeout,
+		},
+		{
+			name:     "Redirect loop",
+			err:      &url.Error{Op: "Get", URL: "http://example.com", Err: fmt.Errorf("stopped after 10 redirects: %w", errTooManyRedirects)},
+			expected: StatusRedirectLoop,
+		},
+		{
+			name:     "Connection reset",
+			err:      &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)},
+			expected: StatusConnectionReset,
+		},
+		{
+			name:     "Empty response",
+			err:      &url.Error{Op: "Get", URL: "http://example.com", Err: io.EOF},
+			expected: StatusEmptyResponse,
+		},
+		{
+			name:     "Certificate invalid",
+			err:      &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}},
+			expected: StatusCertificateInvalid,
+		},
+		{
+			name:     "TLS handshake failed",
+			err:      tls.AlertError(40),
+			expected: StatusTLSHandshakeFailed,
+		},
+		{
+			name:     "Proxy error",
+			err:      &net.OpError{Op: "proxyconnect", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)},
+			expected: StatusProxyError,
+		},
+		{
+			name:     "Substring is not enough",
+			err:      fmt.Errorf("dial tcp: lookup example.com: no such host"),
+			expected: StatusUnknown,
+		},
+		{
+			name:     "Unknown error",
+			err:      fmt.Errorf("some other error"),
+			expected: StatusUnknown,
+		},
+		{
+			name:     "No error",
+			err:      nil,
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := getErrorStatus(tt.err)
+			if got != tt.expected {
+				t.Errorf("getErrorStatus() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFollowRedirect(t *testing.T) {
+	tests := []struct {
+		name            string
+		followRedirects FollowRedirectsConfig
+		expectedCode    int
+		setupServers    func() ([]*httptest.Server, string)
+	}{
+		{
+			name: "follow redirects",
+			followRedirects: FollowRedirectsConfig{
+				Enabled:  true,
+				MaxCount: 10,
+			},
+			expectedCode: http.StatusOK,
+			setupServers: func() ([]*httptest.Server, string) {
+				targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+				redirectServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Redirect(w, r, targetServer.URL, http.StatusFound)
+				}))
+				return []*httptest.Server{targetServer, redirectServer}, redirectServer.URL
+			},
+		},
+		{
+			name: "do not follow redirects",
+			followRedirects: FollowRedirectsConfig{
+				Enabled:  false,
+				MaxCount: 10,
+			},
+			expectedCode: http.StatusFound,
+			setupServers: func() ([]*httptest.Server, string) {
+				targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+				redirectServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					http.Redirect(w, r, targetServer.URL, http.StatusFound)
+				}))
+				return []*httptest.Server{targetServer, redirectServer}, redirectServer.URL
+			},
+		},
+		{
+			name: "follow recursive redirects",
+			followRedirects: FollowRedirectsConfig{
+				Enabled:  true,
+				MaxCount: 10,
+			},
+			expectedCode: http.StatusOK,
+			setupServers: func() ([]*httptest.Server, string) {
+				// Create 3 servers with circular redirects
+				servers := make([]*httptest.Server, 3)
+				for i := range servers {
+					i := i // Capture loop variable
+					servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if i == 2 {
+							w.WriteHeader(http.StatusOK)
+							return
+						}
+						http.Redirect(w, r, servers[i+1].URL, http.StatusFound)
+					}))
+				}
+				return servers, servers[0].URL
+			},
+		},
+		{
+			name: "too many redirects",
+			followRedirects: FollowRedirectsConfig{
+				Enabled:  true,
+				MaxCount: 5,
+			},
+			expectedCode: StatusRedirectLoop,
+			setupServers: func() ([]*httptest.Server, string) {
+				// Create 6 servers with circular redirects
+				servers := make([]*httptest.Server, 6)
+				for i := range servers {
+					i := i // Capture loop variable
+					servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+						if i == len(servers)-1 {
+							w.WriteHeader(http.StatusOK)
+							return
+						}
+						http.Redirect(w, r, servers[i+1].URL, http.StatusFound)
+					}))
+				}
+				return servers, servers[0].URL
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			servers, startURL := tt.setupServers()
+			defer func() {
+				for _, server := range servers {
+					server.Close()
+				}
+			}()
+
+			config := &Config{
+				URL:             startURL,
+				Interval:        100 * time.Millisecond,
+				FollowRedirects: tt.followRedirects,
+				Timeout: TimeoutConfig{
+					Connect: 1 * time.Second,
+					Read:    1 * time.Second,
+				},
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			if err := runChecker(t, ctx, config); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAsserts(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      *Config
+		handler     http.HandlerFunc
+		expectError bool
+	}{
+		{
+			name: "status code match",
+			config: &Config{
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{
+						Values: []int{200, 201},
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+			expectError: false,
+		},
+		{
+			name: "status code mismatch",
+			config: &Config{
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{
+						Values: []int{200, 201},
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectError: true,
+		},
+		{
+			name: "status code regex match",
+			config: &Config{
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{
+						Regex: "^2..$",
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			},
+			expectError: false,
+		},
+		{
+			name: "status code regex mismatch",
+			config: &Config{
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{
+						Regex: "^2..$",
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expectError: true,
+		},
+		{
+			name: "body regex match",
+			config: &Config{
+				Asserts: AssertsConfig{
+					Body: BodyAssert{
+						Regex: "success",
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("operation success"))
+			},
+			expectError: false,
+		},
+		{
+			name: "body regex mismatch",
+			config: &Config{
+				Asserts: AssertsConfig{
+					Body: BodyAssert{
+						Regex: "success",
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("operation failed"))
+			},
+			expectError: true,
+		},
+		{
+			name: "multiple asserts",
+			config: &Config{
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{
+						Values: []int{200},
+					},
+					Body: BodyAssert{
+						Regex: "success",
+					},
+				},
+			},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte("operation success"))
+			},
+			expectError: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			tt.config.URL = server.URL
+			tt.config.Interval = 100 * time.Millisecond
+			tt.config.Timeout = TimeoutConfig{
+				Connect: 1 * time.Second,
+				Read:    1 * time.Second,
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+			defer cancel()
+
+			if err := runChecker(t, ctx, tt.config); err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHooks(t *testing.T) {
+	// Create a temporary script file for testing
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "test_hook.sh")
+	scriptContent := `#!/bin/sh
+echo "hook executed" > "` + filepath.Join(tmpDir, "hook_output.txt") + `"
+`
+	if err := os.WriteFile(scriptPath, []byte(scriptContent), 0755); err != nil {
+		t.Fatalf("Failed to write test script: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Hooks: HooksConfig{
+			OnStart: scriptPath,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	hooks := NewHookSink(config, os.Stderr)
+	hooks.Start()
+	err := runChecker(t, ctx, config, hooks)
+	hooks.Close()
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	// Verify that the hook was executed
+	outputPath := filepath.Join(tmpDir, "hook_output.txt")
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Errorf("Failed to read hook output: %v", err)
+	}
+	if string(content) != "hook executed\n" {
+		t.Errorf("Expected hook output 'hook executed', got %s", string(content))
+	}
+}
+
+func TestMultipleTargets(t *testing.T) {
+	tempDir := t.TempDir()
+	logPath := filepath.Join(tempDir, "test.log")
+
+	var mu sync.Mutex
+	hits := make(map[string]int)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits[r.URL.Path]++
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	logConfig := &LogConfig{
+		Path:   logPath,
+		Format: "{{.statusCode}}",
+	}
+	config := &Config{
+		Targets: []*Config{
+			{
+				Name:     "first",
+				URL:      server.URL + "/first",
+				Interval: 100 * time.Millisecond,
+				Timeout:  TimeoutConfig{Connect: 1 * time.Second, Read: 1 * time.Second},
+				Log:      logConfig,
+			},
+			{
+				Name:     "second",
+				URL:      server.URL + "/second",
+				Interval: 50 * time.Millisecond,
+				Timeout:  TimeoutConfig{Connect: 1 * time.Second, Read: 1 * time.Second},
+				Log:      logConfig,
+			},
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	if err := runChecker(t, ctx, config, NewLogSink(os.Stderr)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits["/first"] == 0 || hits["/second"] == 0 {
+		t.Errorf("Expected both targets to be checked, got %v", hits)
+	}
+	if hits["/second"] <= hits["/first"] {
+		t.Errorf("Expected second target to run on its own shorter interval, got %v", hits)
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(content), "first\t200") || !strings.Contains(string(content), "second\t200") {
+		t.Errorf("Expected log entries tagged with target names, got %s", string(content))
+	}
+}
+
+func TestRequestMethodHeadersBody(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/graphql", http.StatusTemporaryRedirect)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, fmt.Sprintf("%s %s %s %s", r.Method, r.Header.Get("Authorization"), r.Header.Get("Accept"), body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:    server.URL + "/redirect",
+		Method: "POST",
+		Headers: map[string]string{
+			"Authorization": "Bearer secret",
+			"Accept":        "application/json",
+		},
+		Body:     `{"query":"{ health }"}`,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		FollowRedirects: FollowRedirectsConfig{
+			Enabled:  true,
+			MaxCount: 10,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	if err := runChecker(t, ctx, config); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) < 2 {
+		t.Fatalf("Expected at least 2 requests, got %d", len(received))
+	}
+	want := `POST Bearer secret application/json {"query":"{ health }"}`
+	for _, got := range received {
+		if got != want {
+			t.Errorf("Received %q, want %q", got, want)
+		}
+	}
+}
+
+// serveRaw は受け付けた接続ごとに handle を呼び出すローカルの TCP リスナーを起動します
+func serveRaw(t *testing.T, handle func(conn *net.TCPConn)) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn.(*net.TCPConn))
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// readRequest はリクエストヘッダーを読み終えるまで待ちます
+func readRequest(conn net.Conn) {
+	buf := make([]byte, 4096)
+	var received []byte
+	for !strings.Contains(string(received), "\r\n\r\n") {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		received = append(received, buf[:n]...)
+	}
+}
+
+func TestErrorStatusAgainstListeners(t *testing.T) {
+	plainServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer plainServer.Close()
+
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer tlsServer.Close()
+
+	resetAddr := serveRaw(t, func(conn *net.TCPConn) {
+		readRequest(conn)
+		conn.SetLinger(0)
+		conn.Close()
+	})
+
+	emptyAddr := serveRaw(t, func(conn *net.TCPConn) {
+		readRequest(conn)
+		conn.Close()
+	})
+
+	proxyAddr := serveRaw(t, func(conn *net.TCPConn) {
+		readRequest(conn)
+		conn.Write([]byte("HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n"))
+		conn.Close()
+	})
+
+	refused, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	refusedAddr := refused.Addr().String()
+	refused.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		proxy    string
+		expected int
+	}{
+		{
+			name:     "connection refused",
+			url:      "http://" + refusedAddr,
+			expected: StatusConnectionFailed,
+		},
+		{
+			name:     "tls handshake failed",
+			url:      strings.Replace(plainServer.URL, "http://", "https://", 1),
+			expected: StatusTLSHandshakeFailed,
+		},
+		{
+			name:     "certificate invalid",
+			url:      tlsServer.URL,
+			expected: StatusCertificateInvalid,
+		},
+		{
+			name:     "connection reset",
+			url:      "http://" + resetAddr,
+			expected: StatusConnectionReset,
+		},
+		{
+			name:     "empty response",
+			url:      "http://" + emptyAddr,
+			expected: StatusEmptyResponse,
+		},
+		{
+			name:     "proxy refused connect",
+			url:      tlsServer.URL,
+			proxy:    "http://" + proxyAddr,
+			expected: StatusProxyError,
+		},
+		{
+			name:     "proxy unreachable",
+			url:      plainServer.URL,
+			proxy:    "http://" + refusedAddr,
+			expected: StatusProxyError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				URL: tt.url,
+				Timeout: TimeoutConfig{
+					Connect: 1 * time.Second,
+					Read:    1 * time.Second,
+				},
+				Proxy: ProxyConfig{URL: tt.proxy},
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if result.StatusCode != tt.expected {
+				t.Errorf("statusCode = %v (%v), want %v", result.StatusCode, result.Err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBoundedRunModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		failFirst int
+		wantHits  int
+		wantExit  bool
+	}{
+		{
+			name:     "count with all success",
+			config:   Config{Count: 3},
+			wantHits: 3,
+			wantExit: false,
+		},
+		{
+			name:      "count with failures",
+			config:    Config{Count: 3},
+			failFirst: 1,
+			wantHits:  3,
+			wantExit:  true,
+		},
+		{
+			name:      "until success",
+			config:    Config{Until: UntilSuccess, Count: 10},
+			failFirst: 2,
+			wantHits:  3,
+			wantExit:  false,
+		},
+		{
+			name:      "until success consecutive",
+			config:    Config{Until: UntilSuccess, UntilConsecutive: 2, Count: 10},
+			failFirst: 2,
+			wantHits:  4,
+			wantExit:  false,
+		},
+		{
+			name:     "until failure not met within count",
+			config:   Config{Until: UntilFailure, Count: 2},
+			wantHits: 2,
+			wantExit: true,
+		},
+		{
+			name:      "until failure",
+			config:    Config{Until: UntilFailure, Duration: 5 * time.Second},
+			failFirst: 1,
+			wantHits:  1,
+			wantExit:  false,
+		},
+		{
+			name:     "duration",
+			config:   Config{Duration: 180 * time.Millisecond},
+			wantHits: 3,
+			wantExit: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			hits := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				hits++
+				n := hits
+				mu.Unlock()
+				if n <= tt.failFirst {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+			defer server.Close()
+
+			config := tt.config
+			config.URL = server.URL
+			config.Interval = 50 * time.Millisecond
+			config.Timeout = TimeoutConfig{
+				Connect: 1 * time.Second,
+				Read:    1 * time.Second,
+			}
+			config.Asserts = AssertsConfig{
+				StatusCode: StatusCodeAssert{Values: []int{200}},
+			}
+
+			// 条件に達すれば キャンセルしなくても終了すること
+			err := runChecker(t, context.Background(), &config)
+
+			var notPassed *NotPassedError
+			if gotExit := errors.As(err, &notPassed); gotExit != tt.wantExit {
+				t.Errorf("Run() error = %v, want not passed error %v", err, tt.wantExit)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if hits != tt.wantHits {
+				t.Errorf("hits = %d, want %d", hits, tt.wantHits)
+			}
+		})
+	}
+}
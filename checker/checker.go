@@ -0,0 +1,277 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink はチェック結果の出力先です。複数のターゲットから並行して呼ばれます
+type Sink interface {
+	Write(target *Config, result *Result)
+}
+
+// NotPassedError は count・duration・until で区切ったターゲットが条件を満たさずに終わったことを表します
+type NotPassedError struct {
+	Targets []string
+}
+
+func (e *NotPassedError) Error() string {
+	return fmt.Sprintf("check did not pass: %s", strings.Join(e.Targets, ", "))
+}
+
+// Checker は設定されたターゲットをチェックし、結果を Sink に渡します
+type Checker struct {
+	// ErrorLog はリクエストの作成や通知の送信の失敗など、結果として表せないエラーの出力先です。
+	// nil の場合は log パッケージの標準ロガーを使います
+	ErrorLog *log.Logger
+
+	config  *Config
+	targets []*Config
+	clients []*http.Client
+	jars    map[string]*persistentJar
+	sinks   []Sink
+}
+
+// New はターゲットを検証し、ターゲットごとの HTTP クライアントを作成します。
+// 同じ cookie_jar_file を使うターゲットは jar を共有します
+func New(config *Config, sinks ...Sink) (*Checker, error) {
+	c := &Checker{
+		config:  config,
+		targets: config.CheckTargets(),
+		jars:    make(map[string]*persistentJar),
+		sinks:   sinks,
+	}
+	c.clients = make([]*http.Client, len(c.targets))
+	for i, target := range c.targets {
+		if err := target.Validate(); err != nil {
+			return nil, target.wrapError(err)
+		}
+
+		var jar http.CookieJar
+		if target.CookieJarFile != "" {
+			path := filepath.Clean(target.CookieJarFile)
+			if _, ok := c.jars[path]; !ok {
+				persistent, err := openPersistentJar(path)
+				if err != nil {
+					return nil, err
+				}
+				persistent.logf = func(format string, args ...interface{}) {
+					c.logf(nil, format, args...)
+				}
+				c.jars[path] = persistent
+			}
+			jar = c.jars[path]
+		}
+
+		client, err := newClient(target, jar)
+		if err != nil {
+			return nil, target.wrapError(err)
+		}
+		c.clients[i] = client
+	}
+	return c, nil
+}
+
+// wrapError は複数ターゲットのどれで起きたエラーか分かるよう、名前があれば付与します
+func (c *Config) wrapError(err error) error {
+	if c.Name != "" {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+	return err
+}
+
+// logf はターゲット名を付けて ErrorLog に書き出します
+func (c *Checker) logf(target *Config, format string, args ...interface{}) {
+	if target != nil && target.Name != "" {
+		format = "[" + target.Name + "] " + format
+	}
+	if c.ErrorLog != nil {
+		c.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Run は ctx がキャンセルされるか、全ターゲットが count・duration・until の条件に達するまでチェックを繰り返します。
+// 条件を満たさずに終わったターゲットがある場合は *NotPassedError を返します。
+// 設定に応じて Webhook への通知とメトリクスの公開も行い、終了時にはクッキーの jar を保存します
+func (c *Checker) Run(ctx context.Context) error {
+	notifications := newNotifier(c.targets, c.logf)
+	sinks := append(append([]Sink(nil), c.sinks...), notifications)
+	if c.config.Metrics.Listen != "" {
+		m := newMetrics(c.targets)
+		shutdown, err := serveMetrics(c.config.Metrics.Listen, m)
+		if err != nil {
+			return err
+		}
+		defer shutdown()
+		sinks = append(sinks, m)
+	}
+
+	var wg sync.WaitGroup
+	passed := make([]bool, len(c.targets))
+	for i, target := range c.targets {
+		wg.Add(1)
+		go func(i int, target *Config, client *http.Client) {
+			defer wg.Done()
+			passed[i] = c.runTarget(ctx, target, client, sinks)
+		}(i, target, c.clients[i])
+	}
+	wg.Wait()
+	for _, client := range c.clients {
+		client.CloseIdleConnections()
+	}
+	for _, jar := range c.jars {
+		if err := jar.Save(); err != nil {
+			c.logf(nil, "Failed to save cookie jar: %v\n", err)
+		}
+	}
+	notifications.stop()
+
+	var failed []string
+	for i, target := range c.targets {
+		if !passed[i] {
+			failed = append(failed, target.targetName())
+		}
+	}
+	if len(failed) > 0 {
+		return &NotPassedError{Targets: failed}
+	}
+	return nil
+}
+
+// Check は各ターゲットを1回ずつ並行してチェックし、結果を Sink に渡してからターゲットの順に返します。
+// fan_out のターゲットは IP ごとの結果を返します
+func (c *Checker) Check(ctx context.Context) ([]*Result, error) {
+	results := make([][]*Result, len(c.targets))
+	errs := make([]error, len(c.targets))
+	var wg sync.WaitGroup
+	for i, target := range c.targets {
+		wg.Add(1)
+		go func(i int, target *Config, client *http.Client) {
+			defer wg.Done()
+			var fan *fanOut
+			if target.FanOut == FanOutAllIPs {
+				fan = newFanOut(target, client)
+				defer fan.closeIdleConnections()
+			}
+			targetResults, err := checkTarget(ctx, target, client, fan)
+			if err != nil {
+				errs[i] = fmt.Errorf("%s: %w", target.targetName(), err)
+				return
+			}
+			for _, result := range targetResults {
+				for _, sink := range c.sinks {
+					sink.Write(target, result)
+				}
+			}
+			results[i] = targetResults
+		}(i, target, c.clients[i])
+	}
+	wg.Wait()
+
+	var all []*Result
+	for _, targetResults := range results {
+		all = append(all, targetResults...)
+	}
+	return all, errors.Join(errs...)
+}
+
+// checkTarget はターゲットを1回チェックします。fan_out の場合は IP ごとの結果を返します
+func checkTarget(ctx context.Context, config *Config, client *http.Client, fan *fanOut) ([]*Result, error) {
+	if fan != nil {
+		return fan.check(ctx, config)
+	}
+	result, err := checkOnce(ctx, config, client)
+	if err != nil {
+		return nil, err
+	}
+	return []*Result{result}, nil
+}
+
+// runTarget はターゲットのチェックを停止されるか count・duration・until の条件に達するまで繰り返します。
+// until を指定した場合は条件を満たしたかどうか、それ以外で回数か期間を区切った場合は1回以上チェックしてすべて成功したかどうかを返します
+func (c *Checker) runTarget(ctx context.Context, config *Config, client *http.Client, sinks []Sink) bool {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	var deadline <-chan time.Time
+	if config.Duration > 0 {
+		timer := time.NewTimer(config.Duration)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	var fan *fanOut
+	if config.FanOut == FanOutAllIPs {
+		fan = newFanOut(config, client)
+		defer fan.closeIdleConnections()
+	}
+
+	bounded := config.Count > 0 || config.Duration > 0
+	allSucceeded := true
+	checks := 0
+	matched := 0
+	passed := func() bool {
+		return config.Until == "" && allSucceeded && checks > 0
+	}
+	stopped := func() bool {
+		return config.Until == "" && (!bounded || passed())
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return stopped()
+		case <-deadline:
+			return passed()
+		case <-ticker.C:
+			results, err := checkTarget(ctx, config, client, fan)
+			// 停止によって中断されたチェックは結果として扱いません
+			if ctx.Err() != nil {
+				return stopped()
+			}
+
+			// fan_out では全ての IP が成功した場合のみ、その回のチェックを成功とみなします。
+			// リクエストを作成できなかった回も失敗したチェックとして数えます
+			succeeded := true
+			if err != nil {
+				c.logf(config, "Failed to create request: %v\n", err)
+				succeeded = false
+			}
+			for _, result := range results {
+				for _, sink := range sinks {
+					sink.Write(config, result)
+				}
+				if !result.Success() {
+					succeeded = false
+				}
+			}
+
+			checks++
+			if !succeeded {
+				allSucceeded = false
+			}
+			if config.Until != "" {
+				if succeeded == (config.Until == UntilSuccess) {
+					matched++
+				} else {
+					matched = 0
+				}
+				if matched >= config.untilConsecutive() {
+					return true
+				}
+			}
+			if config.Count > 0 && checks >= config.Count {
+				return passed()
+			}
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runChecker は sinks に結果を渡す Checker を作成し、ctx がキャンセルされるか全ターゲットが条件に達するまで Run します
+func runChecker(t *testing.T, ctx context.Context, config *Config, sinks ...Sink) error {
+	t.Helper()
+	c, err := New(config, sinks...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return c.Run(ctx)
+}
+
+// recordingSink は受け取った結果を記録します
+type recordingSink struct {
+	mu      sync.Mutex
+	targets []string
+	results []*Result
+}
+
+func (s *recordingSink) Write(target *Config, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.targets = append(s.targets, target.Name)
+	s.results = append(s.results, result)
+}
+
+func TestCheckerCheck(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Targets = []*Config{DefaultConfig(), DefaultConfig()}
+	config.Targets[0].Name = "up"
+	config.Targets[0].URL = server.URL + "/up"
+	config.Targets[1].Name = "down"
+	config.Targets[1].URL = server.URL + "/down"
+
+	sink := &recordingSink{}
+	c, err := New(config, sink)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	results, err := c.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check() error = %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2", len(results))
+	}
+	if results[0].Target != "up" || !results[0].Success() || results[0].StatusCode != http.StatusOK || string(results[0].Body) != "ok" {
+		t.Errorf("results[0] = %+v", results[0])
+	}
+	if results[1].Target != "down" || results[1].Success() || results[1].StatusText() != "ASSERT_FAILED" || results[1].AssertErr == nil {
+		t.Errorf("results[1] = %+v", results[1])
+	}
+	if results[1].Response == nil || results[1].Response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("results[1].Response = %+v", results[1].Response)
+	}
+	if len(sink.results) != 2 {
+		t.Errorf("sink received %d results, want 2", len(sink.results))
+	}
+}
+
+func TestCheckerCheckRequestError(t *testing.T) {
+	config := DefaultConfig()
+	config.Name = "api"
+	config.URL = "http://example.com"
+	config.Method = "BAD METHOD"
+
+	c, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	results, err := c.Check(context.Background())
+	if err == nil || !strings.HasPrefix(err.Error(), "api: ") {
+		t.Errorf("Check() error = %v, want error prefixed with target name", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("results = %v, want none", results)
+	}
+}
+
+func TestCheckerNewValidates(t *testing.T) {
+	config := DefaultConfig()
+	config.Name = "api"
+	config.URL = "http://example.com"
+	config.Until = "sometimes"
+
+	if _, err := New(config); err == nil || !strings.HasPrefix(err.Error(), "api: ") {
+		t.Errorf("New() error = %v, want validation error prefixed with target name", err)
+	}
+
+	// interval が0以下のターゲットは Run でティッカーを作れないため、作成時に拒否すること
+	config.Until = ""
+	config.Interval = 0
+	if _, err := New(config); err == nil || !strings.Contains(err.Error(), "interval must be positive") {
+		t.Errorf("New() error = %v, want interval error", err)
+	}
+}
+
+func TestCheckerRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Name = "api"
+	config.URL = server.URL
+	config.Interval = 10 * time.Millisecond
+	config.Count = 2
+
+	sink := &recordingSink{}
+	c, err := New(config, sink)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	err = c.Run(context.Background())
+
+	var notPassed *NotPassedError
+	if !errors.As(err, &notPassed) || len(notPassed.Targets) != 1 || notPassed.Targets[0] != "api" {
+		t.Errorf("Run() error = %v, want not passed error for api", err)
+	}
+	if err != nil && err.Error() != "check did not pass: api" {
+		t.Errorf("Run() error = %q", err.Error())
+	}
+	if len(sink.results) != 2 || sink.targets[0] != "api" {
+		t.Errorf("sink received %v for %v, want 2 results for api", sink.results, sink.targets)
+	}
+}
+
+func TestCheckerRunRequestError(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(config *Config)
+	}{
+		{name: "count", modify: func(config *Config) { config.Count = 2 }},
+		{name: "duration", modify: func(config *Config) { config.Duration = 100 * time.Millisecond }},
+		{name: "until success", modify: func(config *Config) { config.Duration = 100 * time.Millisecond; config.Until = UntilSuccess }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Name = "api"
+			config.URL = "http://example.com"
+			config.BodyFile = filepath.Join(t.TempDir(), "missing.json")
+			config.Interval = 10 * time.Millisecond
+			tt.modify(config)
+
+			var errorLog bytes.Buffer
+			c, err := New(config)
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+			c.ErrorLog = log.New(&errorLog, "", 0)
+
+			// リクエストを作成できなかった回は失敗したチェックとして数え、条件を満たしたとはみなさないこと
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			err = c.Run(ctx)
+			var notPassed *NotPassedError
+			if !errors.As(err, &notPassed) {
+				t.Errorf("Run() error = %v, want not passed error", err)
+			}
+			if ctx.Err() != nil {
+				t.Errorf("Run() did not stop on its own")
+			}
+			if tt.name == "count" && strings.Count(errorLog.String(), "Failed to create request") != 2 {
+				t.Errorf("error log = %q, want 2 failed requests", errorLog.String())
+			}
+		})
+	}
+}
+
+func TestCheckerRunCancelBeforeCheck(t *testing.T) {
+	config := DefaultConfig()
+	config.URL = "http://example.com"
+	config.Interval = time.Hour
+	config.Count = 3
+
+	c, err := New(config)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// 1回もチェックせずに停止した回数指定の実行は成功とみなさないこと
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	var notPassed *NotPassedError
+	if err := c.Run(ctx); !errors.As(err, &notPassed) {
+		t.Errorf("Run() error = %v, want not passed error", err)
+	}
+}
+
+func TestCheckerRunCancel(t *testing.T) {
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.URL = server.URL
+	config.Interval = 10 * time.Millisecond
+	config.Timeout.Read = time.Minute
+
+	var errorLog bytes.Buffer
+	sink := &recordingSink{}
+	c, err := New(config, sink)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	c.ErrorLog = log.New(&errorLog, "", 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	// 実行中のリクエストも中断し、中断されたチェックは Sink に渡さないこと
+	finished := make(chan error, 1)
+	go func() {
+		finished <- c.Run(ctx)
+	}()
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run() did not return after cancel")
+	}
+	if len(sink.results) != 0 {
+		t.Errorf("sink received %d results, want 0", len(sink.results))
+	}
+	if errorLog.Len() != 0 {
+		t.Errorf("error log = %q", errorLog.String())
+	}
+}
+
+func TestOutputSink(t *testing.T) {
+	target := &Config{Name: "api", Output: OutputConfig{Timings: true}}
+	result := &Result{
+		RequestedAt: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		StatusCode:  StatusAssertFailed,
+		Duration:    12 * time.Millisecond,
+		AssertErr:   errors.New("body does not match regex ok"),
+		Response:    &http.Response{Header: http.Header{"Content-Type": {"text/plain"}}},
+		Body:        []byte("ng"),
+	}
+
+	var out, errOut bytes.Buffer
+	NewOutputSink(&out, &errOut).Write(target, result)
+
+	wantOut := "2024-01-02T03:04:05.000Z\tapi\tASSERT_FAILED\t12ms\tdns=0s\tconnect=0s\ttls=0s\tttfb=0s\ttransfer=0s\treused=false\n"
+	if out.String() != wantOut {
+		t.Errorf("out = %q, want %q", out.String(), wantOut)
+	}
+	wantErr := "[api] Assert failed: body does not match regex ok\n" +
+		"[api] Response Headers:\n" +
+		"[api]   Content-Type: [text/plain]\n" +
+		"[api] Response Body:\nng\n"
+	if errOut.String() != wantErr {
+		t.Errorf("errOut = %q, want %q", errOut.String(), wantErr)
+	}
+}
@@ -0,0 +1,662 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type TimeoutConfig struct {
+	Connect      time.Duration `yaml:"connect"`
+	TLSHandshake time.Duration `yaml:"tls_handshake"`
+	Read         time.Duration `yaml:"read"`
+}
+
+// tlsHandshake は未設定の場合に接続タイムアウトを流用します
+func (t TimeoutConfig) tlsHandshake() time.Duration {
+	if t.TLSHandshake == 0 {
+		return t.Connect
+	}
+	return t.TLSHandshake
+}
+
+type FollowRedirectsConfig struct {
+	Enabled  bool `yaml:"enabled"`
+	MaxCount int  `yaml:"max_count"`
+}
+
+type StatusCodeAssert struct {
+	Values []int  `yaml:"values"`
+	Regex  string `yaml:"regex"`
+}
+
+type BodyAssert struct {
+	Regex string `yaml:"regex"`
+}
+
+type AssertsConfig struct {
+	StatusCode StatusCodeAssert `yaml:"status_code"`
+	Body       BodyAssert       `yaml:"body"`
+	JSON       []JSONAssert     `yaml:"json"`
+	Headers    []HeaderAssert   `yaml:"headers"`
+	Duration   DurationAssert   `yaml:"duration"`
+	TLS        TLSAssert        `yaml:"tls"`
+	Protocol   string           `yaml:"protocol"`
+}
+
+type CookieConfig struct {
+	Key   string `yaml:"key"`
+	Value string `yaml:"value"`
+}
+
+type LogConfig struct {
+	Path   string `yaml:"path"`
+	Format string `yaml:"format"`
+}
+
+type OutputConfig struct {
+	Format  string `yaml:"format"`
+	Timings bool   `yaml:"timings"`
+}
+
+// ConnectionConfig は接続の再利用の設定です。keep_alive が false の場合はチェックごとに新しく接続します
+type ConnectionConfig struct {
+	KeepAlive   bool          `yaml:"keep_alive"`
+	IdleTimeout time.Duration `yaml:"idle_timeout"`
+	MaxIdle     int           `yaml:"max_idle"`
+}
+
+type MetricsConfig struct {
+	Listen string `yaml:"listen"`
+}
+
+type HooksConfig struct {
+	OnStart           string        `yaml:"on_start"`
+	OnFailure         string        `yaml:"on_failure"`
+	OnRecovery        string        `yaml:"on_recovery"`
+	OnEach            string        `yaml:"on_each"`
+	OnStop            string        `yaml:"on_stop"`
+	FailureThreshold  int           `yaml:"failure_threshold"`
+	RecoveryThreshold int           `yaml:"recovery_threshold"`
+	Timeout           time.Duration `yaml:"timeout"`
+}
+
+// timeout はフック1回の実行時間の上限です。超えたフックは強制終了します
+func (h HooksConfig) timeout() time.Duration {
+	if h.Timeout == 0 {
+		return 30 * time.Second
+	}
+	return h.Timeout
+}
+
+// until に指定できる値
+const (
+	UntilSuccess = "success"
+	UntilFailure = "failure"
+)
+
+type Config struct {
+	Name             string                `yaml:"name"`
+	URL              string                `yaml:"url"`
+	Method           string                `yaml:"method"`
+	Headers          map[string]string     `yaml:"headers"`
+	Body             string                `yaml:"body"`
+	BodyFile         string                `yaml:"body_file"`
+	Interval         time.Duration         `yaml:"interval"`
+	Timeout          TimeoutConfig         `yaml:"timeout"`
+	FollowRedirects  FollowRedirectsConfig `yaml:"follow_redirects"`
+	TLS              TLSConfig             `yaml:"tls"`
+	Connection       ConnectionConfig      `yaml:"connection"`
+	Protocol         string                `yaml:"protocol"`
+	Proxy            ProxyConfig           `yaml:"proxy"`
+	Resolve          map[string]string     `yaml:"resolve"`
+	DNS              DNSConfig             `yaml:"dns"`
+	FanOut           string                `yaml:"fan_out"`
+	Asserts          AssertsConfig         `yaml:"asserts"`
+	Cookies          []CookieConfig        `yaml:"cookies"`
+	CookieFile       string                `yaml:"cookie_file"`
+	CookieJarFile    string                `yaml:"cookie_jar_file"`
+	Log              *LogConfig            `yaml:"log"`
+	Output           OutputConfig          `yaml:"output"`
+	Count            int                   `yaml:"count"`
+	Duration         time.Duration         `yaml:"duration"`
+	Until            string                `yaml:"until"`
+	UntilConsecutive int                   `yaml:"until_consecutive"`
+	Hooks            HooksConfig           `yaml:"hooks"`
+	Notifications    []NotificationConfig  `yaml:"notifications"`
+	Metrics          MetricsConfig         `yaml:"metrics"`
+	Steps            []StepConfig          `yaml:"steps"`
+	Targets          []*Config             `yaml:"-"`
+	startTime        time.Time             // Field to store start time
+}
+
+// targets キーはトップレベルの設定をデフォルトとして各ターゲットに展開するため、個別にデコードします
+type targetsConfig struct {
+	Targets []yaml.Node `yaml:"targets"`
+}
+
+// DefaultConfig は設定ファイルで省略した項目に使うデフォルト値の設定を返します。URL などを設定して使います
+func DefaultConfig() *Config {
+	return &Config{
+		Interval: time.Second,
+		Timeout: TimeoutConfig{
+			Connect:      3 * time.Second,
+			TLSHandshake: 3 * time.Second,
+			Read:         7 * time.Second,
+		},
+		FollowRedirects: FollowRedirectsConfig{
+			Enabled:  true,
+			MaxCount: 10,
+		},
+		Connection: ConnectionConfig{
+			IdleTimeout: 90 * time.Second,
+			MaxIdle:     2,
+		},
+		Asserts: AssertsConfig{
+			StatusCode: StatusCodeAssert{
+				Values: []int{200},
+			},
+		},
+		startTime: time.Now(), // 開始時間を設定
+	}
+}
+
+// LoadConfig は設定ファイルを読み込み、targets があればトップレベルの設定をデフォルトとして各ターゲットに展開します
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, err
+	}
+
+	var raw targetsConfig
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	if len(raw.Targets) == 0 {
+		if err := config.Validate(); err != nil {
+			return nil, err
+		}
+		return config, nil
+	}
+
+	names := make(map[string]bool)
+	for i, node := range raw.Targets {
+		target := config.clone()
+		target.Name = ""
+		target.URL = ""
+		target.Steps = nil
+		if err := node.Decode(target); err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if err := target.Validate(); err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		if target.Name == "" {
+			target.Name = target.targetName()
+		}
+		if names[target.Name] {
+			return nil, fmt.Errorf("targets[%d]: duplicate target name %q", i, target.Name)
+		}
+		names[target.Name] = true
+		config.Targets = append(config.Targets, target)
+	}
+
+	return config, nil
+}
+
+// Validate は設定値とその組み合わせを検証します。targets を持つ設定では各ターゲットを検証してください
+func (c *Config) Validate() error {
+	if c.URL == "" && len(c.Steps) == 0 {
+		return fmt.Errorf("url or steps is required")
+	}
+	vars := make(map[string]string)
+	for i := range c.Steps {
+		if err := c.Steps[i].validate(vars); err != nil {
+			return fmt.Errorf("steps[%d]: %w", i, err)
+		}
+		for _, extract := range c.Steps[i].Extract {
+			vars[extract.Name] = ""
+		}
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("interval must be positive")
+	}
+	if c.Body != "" && c.BodyFile != "" {
+		return fmt.Errorf("body and body_file cannot be used together")
+	}
+	switch c.Output.Format {
+	case "", OutputFormatText, OutputFormatJSONL:
+	default:
+		return fmt.Errorf("unknown output format: %s", c.Output.Format)
+	}
+	if c.Count < 0 || c.Duration < 0 || c.UntilConsecutive < 0 {
+		return fmt.Errorf("count, duration and until_consecutive must not be negative")
+	}
+	switch c.Until {
+	case "", UntilSuccess, UntilFailure:
+	default:
+		return fmt.Errorf("until must be %s or %s: %s", UntilSuccess, UntilFailure, c.Until)
+	}
+	if c.UntilConsecutive > 0 && c.Until == "" {
+		return fmt.Errorf("until_consecutive requires until")
+	}
+	if err := c.TLS.validate(); err != nil {
+		return err
+	}
+	if err := c.Proxy.validate(); err != nil {
+		return err
+	}
+	if c.Connection.IdleTimeout < 0 || c.Connection.MaxIdle < 0 {
+		return fmt.Errorf("connection.idle_timeout and connection.max_idle must not be negative")
+	}
+	if err := c.validateProtocol(); err != nil {
+		return err
+	}
+	if err := validateResolve(c.Resolve); err != nil {
+		return err
+	}
+	if err := c.DNS.validate(); err != nil {
+		return err
+	}
+	switch c.FanOut {
+	case "":
+	case FanOutAllIPs:
+		if len(c.Steps) > 0 {
+			return fmt.Errorf("fan_out cannot be used with steps")
+		}
+		if c.Proxy.URL != "" && !c.Proxy.Disabled {
+			return fmt.Errorf("fan_out connects to each IP directly and cannot be used with proxy.url")
+		}
+	default:
+		return fmt.Errorf("unknown fan_out: %s", c.FanOut)
+	}
+	if err := c.Asserts.validate(); err != nil {
+		return err
+	}
+	if c.Hooks.Timeout < 0 {
+		return fmt.Errorf("hooks.timeout must not be negative")
+	}
+	for i, notification := range c.Notifications {
+		if err := notification.validate(); err != nil {
+			return fmt.Errorf("notifications[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// untilConsecutive は until の条件を満たすのに必要な連続回数です
+func (c *Config) untilConsecutive() int {
+	if c.UntilConsecutive == 0 {
+		return 1
+	}
+	return c.UntilConsecutive
+}
+
+// clone はターゲットのデフォルト値として使うため、ポインタやスライスを含めて設定を複製します
+func (c *Config) clone() *Config {
+	cp := *c
+	cp.Targets = nil
+	cp.Cookies = append([]CookieConfig(nil), c.Cookies...)
+	if c.Headers != nil {
+		// yaml はマップを上書きせずマージするため、ターゲットのヘッダーはデフォルトに追加されます
+		cp.Headers = make(map[string]string, len(c.Headers))
+		for k, v := range c.Headers {
+			cp.Headers[k] = v
+		}
+	}
+	if c.Resolve != nil {
+		cp.Resolve = make(map[string]string, len(c.Resolve))
+		for k, v := range c.Resolve {
+			cp.Resolve[k] = v
+		}
+	}
+	cp.Asserts.StatusCode.Values = append([]int(nil), c.Asserts.StatusCode.Values...)
+	cp.Asserts.JSON = append([]JSONAssert(nil), c.Asserts.JSON...)
+	cp.Asserts.Headers = append([]HeaderAssert(nil), c.Asserts.Headers...)
+	cp.Asserts.TLS.Pins = append([]string(nil), c.Asserts.TLS.Pins...)
+	if c.Log != nil {
+		logConfig := *c.Log
+		cp.Log = &logConfig
+	}
+	return &cp
+}
+
+// CheckTargets は監視対象の一覧を返します。targets が未指定の場合は設定自身が唯一のターゲットです
+func (c *Config) CheckTargets() []*Config {
+	if len(c.Targets) == 0 {
+		return []*Config{c}
+	}
+	return c.Targets
+}
+
+// targetName はメトリクスなどでターゲットを識別する名前です。name が無い場合は URL を使います
+func (c *Config) targetName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.primaryURL()
+}
+
+// primaryURL はターゲットの URL です。シナリオの場合は最初のステップの URL になります
+func (c *Config) primaryURL() string {
+	if c.URL == "" && len(c.Steps) > 0 {
+		return c.Steps[0].URL
+	}
+	return c.URL
+}
+
+// NewRequest は設定されたメソッド・ヘッダー・ボディでリクエストを作成します。
+// ボディは毎回読み直し、リダイレクト時にも再送できるよう bytes.Reader で渡します
+func (c *Config) NewRequest(ctx context.Context) (*http.Request, error) {
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	switch {
+	case c.BodyFile != "":
+		data, err := os.ReadFile(c.BodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read body file: %w", err)
+		}
+		body = bytes.NewReader(data)
+	case c.Body != "":
+		body = strings.NewReader(c.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), c.URL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, v := range c.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+
+	return req, nil
+}
+
+func (c *Config) SetupCookies(jar http.CookieJar) error {
+	targetURL, err := url.Parse(c.primaryURL())
+	if err != nil {
+		return err
+	}
+
+	var cookies []*http.Cookie
+
+	// Add cookies from config
+	for _, cookie := range c.Cookies {
+		cookies = append(cookies, &http.Cookie{
+			Name:  cookie.Key,
+			Value: cookie.Value,
+		})
+	}
+
+	if len(cookies) > 0 {
+		jar.SetCookies(targetURL, cookies)
+	}
+
+	// Add cookies from file
+	// ファイルのクッキーは監視対象の URL ではなく、それぞれのドメインとパスに登録します
+	if c.CookieFile != "" {
+		fileCookies, err := loadCookiesFromFile(c.CookieFile)
+		if err != nil {
+			return err
+		}
+		for _, cookie := range fileCookies {
+			cookieURL, jarCookie := netscapeCookieToJar(cookie)
+			jar.SetCookies(cookieURL, []*http.Cookie{jarCookie})
+		}
+	}
+
+	return nil
+}
+
+// netscapeCookieToJar は loadCookiesFromFile で読み込んだクッキーを、cookiejar に登録する URL とクッキーに変換します。
+// Domain が "." で始まる場合はサブドメインにも送るドメインクッキー、それ以外はホスト限定のクッキーになります
+func netscapeCookieToJar(cookie *http.Cookie) (*url.URL, *http.Cookie) {
+	host := strings.TrimPrefix(cookie.Domain, ".")
+	scheme := "http"
+	if cookie.Secure {
+		scheme = "https"
+	}
+	path := cookie.Path
+	if path == "" {
+		path = "/"
+	}
+
+	jarCookie := *cookie
+	jarCookie.Path = path
+	if strings.HasPrefix(cookie.Domain, ".") {
+		jarCookie.Domain = host
+	} else {
+		jarCookie.Domain = ""
+	}
+
+	return &url.URL{Scheme: scheme, Host: host, Path: path}, &jarCookie
+}
+
+// httpOnlyPrefix は curl が HttpOnly のクッキーのドメインに付ける接頭辞です
+const httpOnlyPrefix = "#HttpOnly_"
+
+// loadCookiesFromFile は curl/Netscape 形式のクッキーファイルを読み込みます。
+// 各行は domain, include subdomains, path, secure, expiry, name, value をタブ区切りで並べたものです。
+// include subdomains が TRUE の場合、返すクッキーの Domain は "." で始まります
+func loadCookiesFromFile(path string) ([]*http.Cookie, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := false
+		if strings.HasPrefix(line, httpOnlyPrefix) {
+			httpOnly = true
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		}
+		if strings.HasPrefix(line, "#") || line == "" {
+			continue
+		}
+
+		// 値が空のクッキーもあるためタブで区切ります。手書きのファイル向けに空白区切りも受け付けます
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			fields = strings.Fields(line)
+		}
+		if len(fields) < 6 {
+			continue
+		}
+		value := ""
+		if len(fields) > 6 {
+			value = fields[6]
+		}
+
+		domain := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			domain = "." + domain
+		}
+
+		cookie := &http.Cookie{
+			Name:     fields[5],
+			Value:    value,
+			Domain:   domain,
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			continue
+		}
+		// 0 はセッションクッキーです
+		if expires > 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, scanner.Err()
+}
+
+var logMu sync.Mutex
+
+// templateFuncs はログや通知のテンプレートで使える関数です
+var templateFuncs = template.FuncMap{
+	// json は値を JSON としてエンコードします。文字列を JSON のペイロードに埋め込む際に使います
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// templateData はログや通知のテンプレートに渡す値です
+func (c *Config) templateData(result *Result) map[string]interface{} {
+	data := map[string]interface{}{
+		"requestedAt":      result.RequestedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+		"statusCode":       result.StatusCode,
+		"duration":         result.Duration,
+		"dnsDuration":      result.Timings.DNS,
+		"connectDuration":  result.Timings.Connect,
+		"tlsDuration":      result.Timings.TLS,
+		"ttfb":             result.Timings.TTFB,
+		"transferDuration": result.Timings.Transfer,
+		"target":           c.Name,
+		"url":              result.URL,
+		"error":            "",
+		"errorMessage":     "",
+		"assertFailure":    "",
+		"steps":            formatSteps(result.Steps),
+		"failedStep":       result.FailedStep,
+		"tlsVersion":       "",
+		"tlsCipher":        "",
+		"tlsIssuer":        "",
+		"tlsSANs":          "",
+		"tlsNotAfter":      "",
+		"tlsDaysRemaining": "",
+		"remoteIP":         result.RemoteIP,
+		"ip":               result.IP,
+		"reused":           result.Reused,
+		"proto":            result.Proto,
+	}
+	if result.TLS != nil {
+		data["tlsVersion"] = result.TLS.Version
+		data["tlsCipher"] = result.TLS.Cipher
+		data["tlsIssuer"] = result.TLS.Issuer
+		data["tlsSANs"] = strings.Join(result.TLS.SANs, ",")
+		if !result.TLS.NotAfter.IsZero() {
+			data["tlsNotAfter"] = result.TLS.NotAfter.Format("2006-01-02T15:04:05Z07:00")
+			data["tlsDaysRemaining"] = result.TLS.DaysRemaining
+		}
+	}
+	if result.StatusCode < 0 {
+		data["error"] = errorMessages[result.StatusCode]
+	}
+	if result.Err != nil {
+		data["errorMessage"] = result.Err.Error()
+	}
+	if result.AssertErr != nil {
+		data["assertFailure"] = result.AssertErr.Error()
+	}
+	return data
+}
+
+// formatLogEntry は出力形式が jsonl であれば JSON 1行を、それ以外は log.format のテンプレートを適用した文字列を返します
+func (c *Config) formatLogEntry(result *Result) (string, error) {
+	if c.Output.Format == OutputFormatJSONL {
+		return c.formatJSONL(result)
+	}
+
+	// Parse log format template
+	formatTmpl, err := template.New("format").Funcs(templateFuncs).Option("missingkey=error").Parse(c.Log.Format)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse format template: %w", err)
+	}
+
+	// 実際のデータでテンプレートを実行
+	var formatBuf bytes.Buffer
+	if err := formatTmpl.Execute(&formatBuf, c.templateData(result)); err != nil {
+		return "", fmt.Errorf("failed to execute format template: %w", err)
+	}
+
+	// タブ文字のエスケープシーケンスを実際のタブ文字に変換
+	entry := strings.ReplaceAll(formatBuf.String(), "\\t", "\t")
+
+	// 複数ターゲットが同じファイルに書き込んでも区別できるよう、format に target が無ければ標準出力と同様に名前を付与します
+	if c.Name != "" && !strings.Contains(c.Log.Format, ".target") {
+		entry = c.Name + "\t" + entry
+	}
+	return entry, nil
+}
+
+func (c *Config) WriteLog(result *Result) error {
+	if c.Log == nil {
+		return nil
+	}
+
+	// Parse log path template
+	pathTmpl, err := template.New("path").Parse(c.Log.Path)
+	if err != nil {
+		return fmt.Errorf("failed to parse path template: %w", err)
+	}
+
+	var pathBuf bytes.Buffer
+	if err := pathTmpl.Execute(&pathBuf, map[string]string{
+		"ymdhms": c.startTime.Format("20060102150405"), // 開始時間を使用
+		"target": c.Name,
+	}); err != nil {
+		return fmt.Errorf("failed to execute path template: %w", err)
+	}
+
+	logEntry, err := c.formatLogEntry(result)
+	if err != nil {
+		return err
+	}
+
+	// 複数ターゲットが同じログファイルに書き込むため排他します
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	// Open log file in append mode
+	f, err := os.OpenFile(pathBuf.String(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer f.Close()
+
+	// Write log entry
+	if _, err := fmt.Fprintln(f, logEntry); err != nil {
+		return fmt.Errorf("failed to write log: %w", err)
+	}
+
+	return nil
+}
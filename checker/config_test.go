@@ -0,0 +1,614 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name:    "minimal config",
+			content: `url: https://example.com`,
+			want: &Config{
+				URL:      "https://example.com",
+				Interval: time.Second,
+				Timeout: TimeoutConfig{
+					Connect: 3 * time.Second,
+					Read:    7 * time.Second,
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "full config",
+			content: `url: https://example.com
+interval: 2s
+timeout:
+  connect: 5s
+  read: 10s
+cookies:
+  - key: session
+    value: abc123
+cookie_file: /tmp/cookies.txt
+log:
+  path: /tmp/log{{.ymdhms}}.txt
+  format: "{{.requestedAt}}\t{{.statusCode}}\t{{.duration}}"`,
+			want: &Config{
+				URL:      "https://example.com",
+				Interval: 2 * time.Second,
+				Timeout: TimeoutConfig{
+					Connect: 5 * time.Second,
+					Read:    10 * time.Second,
+				},
+				Cookies: []CookieConfig{
+					{
+						Key:   "session",
+						Value: "abc123",
+					},
+				},
+				CookieFile: "/tmp/cookies.txt",
+				Log: &LogConfig{
+					Path:   "/tmp/log{{.ymdhms}}.txt",
+					Format: "{{.requestedAt}}\t{{.statusCode}}\t{{.duration}}",
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "body and body_file",
+			content: `url: https://example.com
+body: hello
+body_file: /tmp/body.txt`,
+			wantErr: true,
+		},
+		{
+			name: "unknown output format",
+			content: `url: https://example.com
+output:
+  format: xml`,
+			wantErr: true,
+		},
+		{
+			name: "unknown until",
+			content: `url: https://example.com
+until: forever`,
+			wantErr: true,
+		},
+		{
+			name: "zero interval",
+			content: `url: https://example.com
+interval: 0s`,
+			wantErr: true,
+		},
+		{
+			name: "negative interval",
+			content: `url: https://example.com
+interval: -1s`,
+			wantErr: true,
+		},
+		{
+			name: "until_consecutive without until",
+			content: `url: https://example.com
+until_consecutive: 3`,
+			wantErr: true,
+		},
+		{
+			name:    "empty config",
+			content: ``,
+			want: &Config{
+				Interval: time.Second,
+				Timeout: TimeoutConfig{
+					Connect: 3 * time.Second,
+					Read:    7 * time.Second,
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary config file
+			tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(tmpFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			got, err := LoadConfig(tmpFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				if got.URL != tt.want.URL {
+					t.Errorf("URL = %v, want %v", got.URL, tt.want.URL)
+				}
+				if got.Interval != tt.want.Interval {
+					t.Errorf("Interval = %v, want %v", got.Interval, tt.want.Interval)
+				}
+				if got.Timeout.Connect != tt.want.Timeout.Connect {
+					t.Errorf("Timeout.Connect = %v, want %v", got.Timeout.Connect, tt.want.Timeout.Connect)
+				}
+				if got.Timeout.Read != tt.want.Timeout.Read {
+					t.Errorf("Timeout.Read = %v, want %v", got.Timeout.Read, tt.want.Timeout.Read)
+				}
+				if len(got.Cookies) != len(tt.want.Cookies) {
+					t.Errorf("Cookies length = %v, want %v", len(got.Cookies), len(tt.want.Cookies))
+				}
+				if got.CookieFile != tt.want.CookieFile {
+					t.Errorf("CookieFile = %v, want %v", got.CookieFile, tt.want.CookieFile)
+				}
+				if (got.Log == nil) != (tt.want.Log == nil) {
+					t.Errorf("Log = %v, want %v", got.Log, tt.want.Log)
+				}
+				if got.Log != nil && tt.want.Log != nil {
+					if got.Log.Path != tt.want.Log.Path {
+						t.Errorf("Log.Path = %v, want %v", got.Log.Path, tt.want.Log.Path)
+					}
+					if got.Log.Format != tt.want.Log.Format {
+						t.Errorf("Log.Format = %v, want %v", got.Log.Format, tt.want.Log.Format)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCookiesFromFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "valid cookies",
+			content: `# Netscape HTTP Cookie File
+# https://curl.haxx.se/rfc/cookie_spec.html
+# This is a generated file!  Do not edit.
+
+.example.com	TRUE	/	FALSE	1735689600	session	abc123
+.example.com	TRUE	/	FALSE	1735689600	user	xyz789`,
+			want:    2,
+			wantErr: false,
+		},
+		{
+			name: "empty file",
+			content: `# Netscape HTTP Cookie File
+# https://curl.haxx.se/rfc/cookie_spec.html`,
+			want:    0,
+			wantErr: false,
+		},
+		{
+			name: "invalid format",
+			content: `invalid
+format
+here`,
+			want:    0,
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary cookie file
+			tmpFile := filepath.Join(t.TempDir(), "cookies.txt")
+			if err := os.WriteFile(tmpFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test cookie file: %v", err)
+			}
+
+			got, err := loadCookiesFromFile(tmpFile)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("loadCookiesFromFile() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if len(got) != tt.want {
+				t.Errorf("loadCookiesFromFile() got %d cookies, want %d", len(got), tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteLog(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *LogConfig
+		status   int
+		duration time.Duration
+		timings  Timings
+		want     string
+		wantErr  bool
+	}{
+		{
+			name: "simple format",
+			config: &LogConfig{
+				Path:   "test.log",
+				Format: "{{.statusCode}}",
+			},
+			status:   200,
+			duration: 100 * time.Millisecond,
+			want:     "200",
+			wantErr:  false,
+		},
+		{
+			name: "full format",
+			config: &LogConfig{
+				Path:   "test.log",
+				Format: "{{.requestedAt}}\t{{.statusCode}}\t{{.duration}}",
+			},
+			status:   404,
+			duration: 150 * time.Millisecond,
+			want:     "404",
+			wantErr:  false,
+		},
+		{
+			name: "timings format",
+			config: &LogConfig{
+				Path:   "test.log",
+				Format: "{{.dnsDuration}} {{.connectDuration}} {{.tlsDuration}} {{.ttfb}} {{.transferDuration}}",
+			},
+			status:   200,
+			duration: 100 * time.Millisecond,
+			timings: Timings{
+				DNS:      1 * time.Millisecond,
+				Connect:  2 * time.Millisecond,
+				TLS:      3 * time.Millisecond,
+				TTFB:     4 * time.Millisecond,
+				Transfer: 5 * time.Millisecond,
+			},
+			want:    "1ms 2ms 3ms 4ms 5ms",
+			wantErr: false,
+		},
+		{
+			name: "invalid template",
+			config: &LogConfig{
+				Path:   "test.log",
+				Format: "{{.Invalid}}",
+			},
+			status:   200,
+			duration: 100 * time.Millisecond,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Create temporary directory for log files
+			tmpDir := t.TempDir()
+			tt.config.Path = filepath.Join(tmpDir, tt.config.Path)
+
+			config := &Config{
+				Log: tt.config,
+			}
+
+			err := config.WriteLog(&Result{
+				RequestedAt: time.Now(),
+				StatusCode:  tt.status,
+				Duration:    tt.duration,
+				Timings:     tt.timings,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("WriteLog() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			if !tt.wantErr {
+				content, err := os.ReadFile(tt.config.Path)
+				if err != nil {
+					t.Errorf("Failed to read log file: %v", err)
+					return
+				}
+
+				if !filepath.IsAbs(tt.config.Path) {
+					t.Errorf("Log file path is not absolute: %s", tt.config.Path)
+				}
+				if !strings.Contains(string(content), tt.want) {
+					t.Errorf("Log content = %s, want %s", string(content), tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatLogEntryTargetName(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		format string
+		want   string
+	}{
+		{name: "unnamed", format: "{{.statusCode}}", want: "200"},
+		{name: "prefixed", target: "api", format: "{{.statusCode}}", want: "api\t200"},
+		{name: "format has target", target: "api", format: "{{.statusCode}}\t{{.target}}", want: "200\tapi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{Name: tt.target, Log: &LogConfig{Format: tt.format}}
+			got, err := config.formatLogEntry(&Result{StatusCode: 200})
+			if err != nil {
+				t.Fatalf("formatLogEntry() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("formatLogEntry() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfigTargets(t *testing.T) {
+	content := `interval: 2s
+timeout:
+  connect: 5s
+  read: 10s
+log:
+  path: /tmp/{{.target}}.log
+  format: "{{.target}}\t{{.statusCode}}"
+targets:
+  - name: api
+    url: https://example.com/api
+    interval: 500ms
+  - url: https://example.com/health
+    timeout:
+      read: 1s
+    log:
+      path: /tmp/health.log`
+
+	tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if len(config.Targets) != 2 {
+		t.Fatalf("Targets length = %d, want 2", len(config.Targets))
+	}
+
+	api := config.Targets[0]
+	if api.Name != "api" {
+		t.Errorf("Targets[0].Name = %v, want api", api.Name)
+	}
+	if api.Interval != 500*time.Millisecond {
+		t.Errorf("Targets[0].Interval = %v, want 500ms", api.Interval)
+	}
+	if api.Timeout.Read != 10*time.Second {
+		t.Errorf("Targets[0].Timeout.Read = %v, want 10s", api.Timeout.Read)
+	}
+	if api.Log.Path != "/tmp/{{.target}}.log" {
+		t.Errorf("Targets[0].Log.Path = %v, want inherited path", api.Log.Path)
+	}
+
+	health := config.Targets[1]
+	if health.Name != "https://example.com/health" {
+		t.Errorf("Targets[1].Name = %v, want url as default name", health.Name)
+	}
+	if health.Interval != 2*time.Second {
+		t.Errorf("Targets[1].Interval = %v, want 2s", health.Interval)
+	}
+	if health.Timeout.Connect != 5*time.Second || health.Timeout.Read != time.Second {
+		t.Errorf("Targets[1].Timeout = %+v, want connect 5s / read 1s", health.Timeout)
+	}
+	if health.Log.Path != "/tmp/health.log" || health.Log.Format != "{{.target}}\t{{.statusCode}}" {
+		t.Errorf("Targets[1].Log = %+v, want overridden path and inherited format", health.Log)
+	}
+	if config.Log.Path != "/tmp/{{.target}}.log" {
+		t.Errorf("top-level Log.Path was modified: %v", config.Log.Path)
+	}
+}
+
+func TestLoadConfigTargetsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "missing url",
+			content: `targets:
+  - name: api`,
+		},
+		{
+			name: "duplicate name",
+			content: `targets:
+  - name: api
+    url: https://example.com/a
+  - name: api
+    url: https://example.com/b`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(tmpFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+
+			if _, err := LoadConfig(tmpFile); err == nil {
+				t.Errorf("LoadConfig() expected error")
+			}
+		})
+	}
+}
+
+func TestNewRequest(t *testing.T) {
+	bodyFile := filepath.Join(t.TempDir(), "body.json")
+	if err := os.WriteFile(bodyFile, []byte(`{"query":"{ health }"}`), 0644); err != nil {
+		t.Fatalf("Failed to write body file: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		config     *Config
+		wantMethod string
+		wantBody   string
+		wantHost   string
+	}{
+		{
+			name:       "default GET",
+			config:     &Config{URL: "http://example.com"},
+			wantMethod: "GET",
+		},
+		{
+			name: "inline body",
+			config: &Config{
+				URL:     "http://example.com",
+				Method:  "post",
+				Headers: map[string]string{"Authorization": "Bearer token", "Host": "internal.example.com"},
+				Body:    "hello",
+			},
+			wantMethod: "POST",
+			wantBody:   "hello",
+			wantHost:   "internal.example.com",
+		},
+		{
+			name: "body file",
+			config: &Config{
+				URL:      "http://example.com",
+				Method:   "PUT",
+				BodyFile: bodyFile,
+			},
+			wantMethod: "PUT",
+			wantBody:   `{"query":"{ health }"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.config.NewRequest(context.Background())
+			if err != nil {
+				t.Fatalf("NewRequest() error = %v", err)
+			}
+			if req.Method != tt.wantMethod {
+				t.Errorf("Method = %v, want %v", req.Method, tt.wantMethod)
+			}
+			for k, v := range tt.config.Headers {
+				if k == "Host" {
+					continue
+				}
+				if got := req.Header.Get(k); got != v {
+					t.Errorf("Header %s = %v, want %v", k, got, v)
+				}
+			}
+			if tt.wantHost != "" && req.Host != tt.wantHost {
+				t.Errorf("Host = %v, want %v", req.Host, tt.wantHost)
+			}
+			if tt.wantBody == "" {
+				if req.Body != nil {
+					t.Errorf("Body = %v, want nil", req.Body)
+				}
+				return
+			}
+			// GetBody で何度でも読み直せること
+			for i := 0; i < 2; i++ {
+				body, err := req.GetBody()
+				if err != nil {
+					t.Fatalf("GetBody() error = %v", err)
+				}
+				got, _ := io.ReadAll(body)
+				if string(got) != tt.wantBody {
+					t.Errorf("Body = %s, want %s", got, tt.wantBody)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadCookiesFromFileAttributes(t *testing.T) {
+	content := "# Netscape HTTP Cookie File\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tsession\tabc123\n" +
+		"#HttpOnly_sso.example.com\tFALSE\t/login\tTRUE\t1735689600\tsso\txyz789\n" +
+		"example.org\tFALSE\t/\tFALSE\t0\tempty\t\n"
+
+	tmpFile := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test cookie file: %v", err)
+	}
+
+	got, err := loadCookiesFromFile(tmpFile)
+	if err != nil {
+		t.Fatalf("loadCookiesFromFile() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("loadCookiesFromFile() got %d cookies, want 3", len(got))
+	}
+
+	session := got[0]
+	if session.Name != "session" || session.Value != "abc123" || session.Domain != ".example.com" ||
+		session.Path != "/" || session.Secure || session.HttpOnly || !session.Expires.IsZero() {
+		t.Errorf("session cookie = %+v", session)
+	}
+
+	sso := got[1]
+	if sso.Name != "sso" || sso.Domain != "sso.example.com" || sso.Path != "/login" ||
+		!sso.Secure || !sso.HttpOnly || !sso.Expires.Equal(time.Unix(1735689600, 0)) {
+		t.Errorf("sso cookie = %+v", sso)
+	}
+
+	empty := got[2]
+	if empty.Name != "empty" || empty.Value != "" || empty.Domain != "example.org" {
+		t.Errorf("empty cookie = %+v", empty)
+	}
+}
+
+func TestSetupCookiesScoping(t *testing.T) {
+	expires := time.Now().Add(time.Hour).Unix()
+	content := fmt.Sprintf("# Netscape HTTP Cookie File\n"+
+		".example.com\tTRUE\t/\tFALSE\t%d\tshared\t1\n"+
+		"#HttpOnly_sso.example.com\tFALSE\t/login\tTRUE\t%[1]d\tsso\t2\n"+
+		"example.com\tFALSE\t/\tFALSE\t1\texpired\t3\n", expires)
+
+	tmpFile := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test cookie file: %v", err)
+	}
+
+	jar, _ := cookiejar.New(nil)
+	config := &Config{URL: "https://www.example.com", CookieFile: tmpFile}
+	if err := config.SetupCookies(jar); err != nil {
+		t.Fatalf("SetupCookies() error = %v", err)
+	}
+
+	tests := []struct {
+		url  string
+		want []string
+	}{
+		{url: "https://www.example.com/", want: []string{"shared"}},
+		{url: "https://sso.example.com/login/form", want: []string{"shared", "sso"}},
+		{url: "http://sso.example.com/login", want: []string{"shared"}},
+		{url: "https://sso.example.com/other", want: []string{"shared"}},
+		{url: "https://example.org/", want: nil},
+	}
+
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		var names []string
+		for _, cookie := range jar.Cookies(u) {
+			names = append(names, cookie.Name)
+		}
+		sort.Strings(names)
+		if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("cookies for %s = %v, want %v", tt.url, names, tt.want)
+		}
+	}
+}
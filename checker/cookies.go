@@ -0,0 +1,209 @@
+package checker
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// persistentJar は cookiejar.Jar に受け取ったクッキーを記録し、変化があるたびに curl 形式のファイルへ書き戻します。
+// cookiejar.Jar は保持しているクッキーを列挙できないため、登録されたクッキーを別に管理します
+type persistentJar struct {
+	*cookiejar.Jar
+	path    string
+	mu      sync.Mutex
+	entries map[string]*http.Cookie
+	// logf は保存の失敗を報告します。nil の場合は報告しません
+	logf func(format string, args ...interface{})
+}
+
+// openPersistentJar はファイルが存在すれば読み込んで jar を作成します
+func openPersistentJar(path string) (*persistentJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cookie jar: %w", err)
+	}
+	j := &persistentJar{
+		Jar:     jar,
+		path:    path,
+		entries: make(map[string]*http.Cookie),
+	}
+
+	cookies, err := loadCookiesFromFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load cookie jar file: %w", err)
+	}
+	for _, cookie := range cookies {
+		cookieURL, jarCookie := netscapeCookieToJar(cookie)
+		j.Jar.SetCookies(cookieURL, []*http.Cookie{jarCookie})
+		j.record(cookieURL, jarCookie, time.Now())
+	}
+
+	return j, nil
+}
+
+// SetCookies はレスポンスの Set-Cookie を jar に登録し、内容が変わった場合はファイルに保存します
+func (j *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.Jar.SetCookies(u, cookies)
+
+	j.mu.Lock()
+	changed := false
+	now := time.Now()
+	for _, cookie := range cookies {
+		if j.record(u, cookie, now) {
+			changed = true
+		}
+	}
+	j.mu.Unlock()
+
+	if changed {
+		if err := j.Save(); err != nil && j.logf != nil {
+			j.logf("Failed to save cookie jar: %v\n", err)
+		}
+	}
+}
+
+// record は cookiejar と同じ規則でクッキーの有効なドメインとパスを求めて記録し、変化があったかどうかを返します。
+// 記録するクッキーの Domain は、ドメインクッキーであれば "." で始まります
+func (j *persistentJar) record(u *url.URL, cookie *http.Cookie, now time.Time) bool {
+	host := strings.ToLower(u.Hostname())
+	domain := host
+	if cookie.Domain != "" {
+		d := strings.ToLower(strings.TrimPrefix(cookie.Domain, "."))
+		if d != host && !strings.HasSuffix(host, "."+d) {
+			// cookiejar が拒否するドメインです
+			return false
+		}
+		if net.ParseIP(host) == nil {
+			domain = "." + d
+		}
+	}
+
+	cookiePath := cookie.Path
+	if cookiePath == "" || !strings.HasPrefix(cookiePath, "/") {
+		cookiePath = defaultCookiePath(u.Path)
+	}
+
+	key := domain + ";" + cookiePath + ";" + cookie.Name
+	old, exists := j.entries[key]
+
+	var expires time.Time
+	switch {
+	case cookie.MaxAge < 0:
+		expires = now
+	case cookie.MaxAge > 0:
+		expires = now.Add(time.Duration(cookie.MaxAge) * time.Second)
+	default:
+		expires = cookie.Expires
+	}
+	if !expires.IsZero() && !expires.After(now) {
+		delete(j.entries, key)
+		return exists
+	}
+
+	entry := &http.Cookie{
+		Name:     cookie.Name,
+		Value:    cookie.Value,
+		Domain:   domain,
+		Path:     cookiePath,
+		Expires:  expires,
+		Secure:   cookie.Secure,
+		HttpOnly: cookie.HttpOnly,
+	}
+	j.entries[key] = entry
+
+	return !exists || old.Value != entry.Value || !old.Expires.Equal(entry.Expires) ||
+		old.Secure != entry.Secure || old.HttpOnly != entry.HttpOnly
+}
+
+// defaultCookiePath は RFC 6265 5.1.4 のデフォルトパスです
+func defaultCookiePath(urlPath string) string {
+	if urlPath == "" || urlPath[0] != '/' {
+		return "/"
+	}
+	dir := path.Dir(urlPath)
+	if urlPath[len(urlPath)-1] == '/' {
+		dir = strings.TrimSuffix(urlPath, "/")
+	}
+	if dir == "" || dir == "." {
+		return "/"
+	}
+	return dir
+}
+
+// Save は有効なクッキーを curl 形式で一時ファイルに書き出し、rename で置き換えます
+func (j *persistentJar) Save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]string, 0, len(j.entries))
+	for key, cookie := range j.entries {
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	tmp, err := os.CreateTemp(filepath.Dir(j.path), "."+filepath.Base(j.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	fmt.Fprintln(w, "# Netscape HTTP Cookie File")
+	fmt.Fprintln(w, "# This file was generated by chechekule! Edit at your own risk.")
+	fmt.Fprintln(w)
+	for _, key := range keys {
+		fmt.Fprintln(w, formatNetscapeCookie(j.entries[key]))
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), j.path)
+}
+
+func netscapeBool(v bool) string {
+	if v {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// formatNetscapeCookie は loadCookiesFromFile で読み込める1行に変換します
+func formatNetscapeCookie(cookie *http.Cookie) string {
+	domain := cookie.Domain
+	if cookie.HttpOnly {
+		domain = httpOnlyPrefix + domain
+	}
+	var expires int64
+	if !cookie.Expires.IsZero() {
+		expires = cookie.Expires.Unix()
+	}
+	return strings.Join([]string{
+		domain,
+		netscapeBool(strings.HasPrefix(cookie.Domain, ".")),
+		cookie.Path,
+		netscapeBool(cookie.Secure),
+		fmt.Sprint(expires),
+		cookie.Name,
+		cookie.Value,
+	}, "\t")
+}
@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPersistentJarRoundTrip(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "jar.txt")
+
+	jar, err := openPersistentJar(jarPath)
+	if err != nil {
+		t.Fatalf("openPersistentJar() error = %v", err)
+	}
+
+	u, _ := url.Parse("https://www.example.com/app/login")
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc", HttpOnly: true, Secure: true},
+		{Name: "shared", Value: "1", Domain: "example.com", Path: "/", Expires: expires},
+		{Name: "other", Value: "x", Domain: "other.com"},
+	})
+
+	content, err := os.ReadFile(jarPath)
+	if err != nil {
+		t.Fatalf("jar file was not written: %v", err)
+	}
+	for _, want := range []string{
+		"#HttpOnly_www.example.com\tFALSE\t/app\tTRUE\t0\tsession\tabc",
+		fmt.Sprintf(".example.com\tTRUE\t/\tFALSE\t%d\tshared\t1", expires.Unix()),
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("jar file does not contain %q\n%s", want, content)
+		}
+	}
+	if strings.Contains(string(content), "other.com") {
+		t.Errorf("cookie for a foreign domain must not be saved\n%s", content)
+	}
+
+	// 再起動後も同じクッキーが送られること
+	reopened, err := openPersistentJar(jarPath)
+	if err != nil {
+		t.Fatalf("openPersistentJar() error = %v", err)
+	}
+	got := map[string]string{}
+	for _, cookie := range reopened.Cookies(u) {
+		got[cookie.Name] = cookie.Value
+	}
+	if got["session"] != "abc" || got["shared"] != "1" {
+		t.Errorf("reloaded cookies = %v", got)
+	}
+
+	// Max-Age で削除されたクッキーはファイルからも消えること
+	reopened.SetCookies(u, []*http.Cookie{{Name: "session", Value: "", Path: "/app", MaxAge: -1}})
+	content, _ = os.ReadFile(jarPath)
+	if strings.Contains(string(content), "\tsession\t") {
+		t.Errorf("deleted cookie is still saved\n%s", content)
+	}
+}
+
+func TestDefaultCookiePath(t *testing.T) {
+	tests := map[string]string{
+		"":             "/",
+		"/":            "/",
+		"/login":       "/",
+		"/app/login":   "/app",
+		"/app/":        "/app",
+		"/app/a/b.php": "/app/a",
+	}
+	for in, want := range tests {
+		if got := defaultCookiePath(in); got != want {
+			t.Errorf("defaultCookiePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestCookieJarFileSurvivesRestart(t *testing.T) {
+	jarPath := filepath.Join(t.TempDir(), "jar.txt")
+
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		received = append(received, r.Header.Get("Cookie"))
+		mu.Unlock()
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "logged-in", Path: "/"})
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	newConfig := func() *Config {
+		return &Config{
+			URL:           server.URL,
+			Interval:      50 * time.Millisecond,
+			Count:         1,
+			CookieJarFile: jarPath,
+			Timeout: TimeoutConfig{
+				Connect: 1 * time.Second,
+				Read:    1 * time.Second,
+			},
+		}
+	}
+
+	if err := runChecker(t, context.Background(), newConfig()); err != nil {
+		t.Fatalf("first run error = %v", err)
+	}
+	if err := runChecker(t, context.Background(), newConfig()); err != nil {
+		t.Fatalf("second run error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || received[0] != "" || received[1] != "session=logged-in" {
+		t.Errorf("received cookies = %q, want the session to survive the restart", received)
+	}
+}
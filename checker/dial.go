@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// DNSConfig は名前解決の設定です
+type DNSConfig struct {
+	Server    string `yaml:"server"`
+	IPVersion int    `yaml:"ip_version"`
+}
+
+func (d *DNSConfig) validate() error {
+	switch d.IPVersion {
+	case 0, 4, 6:
+	default:
+		return fmt.Errorf("dns.ip_version must be 4 or 6: %d", d.IPVersion)
+	}
+	if d.Server != "" {
+		if _, _, err := net.SplitHostPort(d.serverAddr()); err != nil {
+			return fmt.Errorf("invalid dns.server: %w", err)
+		}
+	}
+	return nil
+}
+
+// serverAddr はポートが省略されていれば 53 を補ったリゾルバーのアドレスです
+func (d *DNSConfig) serverAddr() string {
+	if _, _, err := net.SplitHostPort(d.Server); err == nil {
+		return d.Server
+	}
+	return net.JoinHostPort(d.Server, "53")
+}
+
+// network は ip_version に応じてダイヤルするネットワークを絞り込みます
+func (d *DNSConfig) network(network string) string {
+	switch d.IPVersion {
+	case 4:
+		return network + "4"
+	case 6:
+		return network + "6"
+	}
+	return network
+}
+
+// validateResolve は resolve のキーが host:port か host、値が IP アドレス(ポート付きも可)であることを確認します
+func validateResolve(resolve map[string]string) error {
+	for key, value := range resolve {
+		if key == "" {
+			return fmt.Errorf("resolve: empty host")
+		}
+		if _, _, err := resolveTarget(value); err != nil {
+			return fmt.Errorf("resolve[%s]: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// resolveTarget は resolve の値を IP とポートに分けます。ポートが無い場合は空文字列です
+func resolveTarget(value string) (ip, port string, err error) {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		host, port = value, ""
+	}
+	if net.ParseIP(host) == nil {
+		return "", "", fmt.Errorf("not an IP address: %s", value)
+	}
+	return host, port, nil
+}
+
+// overrideDialer は curl の --resolve のように、特定のホストへの接続先を差し替えます。
+// URL のホストはそのままなので Host ヘッダーと SNI は変わりません
+type overrideDialer struct {
+	dialer  *net.Dialer
+	resolve map[string]string
+	dns     DNSConfig
+}
+
+// newDialer は resolve・dns の設定を反映したダイヤラーを作成します
+func (c *Config) newDialer() *overrideDialer {
+	dialer := &net.Dialer{
+		Timeout: c.Timeout.Connect,
+	}
+	if c.DNS.Server != "" {
+		server := c.DNS.serverAddr()
+		dialer.Resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+	return &overrideDialer{
+		dialer:  dialer,
+		resolve: c.Resolve,
+		dns:     c.DNS,
+	}
+}
+
+// override は host:port、次に host の順で resolve を引き、接続先のアドレスを返します
+func (d *overrideDialer) override(addr string) string {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	value, ok := d.resolve[addr]
+	if !ok {
+		if value, ok = d.resolve[host]; !ok {
+			return addr
+		}
+	}
+	ip, overridePort, err := resolveTarget(value)
+	if err != nil {
+		return addr
+	}
+	if overridePort != "" {
+		port = overridePort
+	}
+	return net.JoinHostPort(ip, port)
+}
+
+func (d *overrideDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.dialer.DialContext(ctx, d.dns.network(network), d.override(addr))
+}
@@ -0,0 +1,229 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOverrideDialer(t *testing.T) {
+	dialer := (&Config{
+		Resolve: map[string]string{
+			"example.com:443": "10.0.0.5",
+			"example.com":     "10.0.0.6",
+			"api.example.com": "[2001:db8::1]:8443",
+		},
+	}).newDialer()
+
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{addr: "example.com:443", want: "10.0.0.5:443"},
+		{addr: "example.com:80", want: "10.0.0.6:80"},
+		{addr: "api.example.com:443", want: "[2001:db8::1]:8443"},
+		{addr: "other.example.com:443", want: "other.example.com:443"},
+	}
+	for _, tt := range tests {
+		if got := dialer.override(tt.addr); got != tt.want {
+			t.Errorf("override(%q) = %q, want %q", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestDNSConfig(t *testing.T) {
+	if got := (&DNSConfig{Server: "8.8.8.8"}).serverAddr(); got != "8.8.8.8:53" {
+		t.Errorf("serverAddr() = %q", got)
+	}
+	if got := (&DNSConfig{Server: "[2001:db8::53]:5353"}).serverAddr(); got != "[2001:db8::53]:5353" {
+		t.Errorf("serverAddr() = %q", got)
+	}
+	if got := (&DNSConfig{IPVersion: 4}).network("tcp"); got != "tcp4" {
+		t.Errorf("network() = %q", got)
+	}
+	if got := (&DNSConfig{}).network("tcp"); got != "tcp" {
+		t.Errorf("network() = %q", got)
+	}
+
+	for _, config := range []Config{
+		{URL: "http://example.com", Interval: time.Second, DNS: DNSConfig{IPVersion: 5}},
+		{URL: "http://example.com", Interval: time.Second, Resolve: map[string]string{"example.com:443": "not-an-ip"}},
+	} {
+		if err := config.Validate(); err == nil {
+			t.Errorf("validate(%+v) expected error", config)
+		}
+	}
+}
+
+func TestResolveOverride(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.TLS.ServerName))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	tests := []struct {
+		name       string
+		url        string
+		resolve    map[string]string
+		dns        DNSConfig
+		wantStatus int
+	}{
+		{
+			name:       "host and port",
+			url:        "https://example.com:" + port,
+			resolve:    map[string]string{"example.com:" + port: "127.0.0.1"},
+			wantStatus: 200,
+		},
+		{
+			name:       "host with port override",
+			url:        "https://example.com:" + port,
+			resolve:    map[string]string{"example.com": "127.0.0.1:" + port},
+			wantStatus: 200,
+		},
+		{
+			name:       "ipv6 only",
+			url:        "https://example.com:" + port,
+			resolve:    map[string]string{"example.com": "127.0.0.1"},
+			dns:        DNSConfig{IPVersion: 6},
+			wantStatus: StatusConnectionFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				URL:     tt.url,
+				Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+				Resolve: tt.resolve,
+				DNS:     tt.dns,
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{Values: []int{200}},
+					// Host ヘッダーと SNI は URL のホストのままです
+					Body: BodyAssert{Regex: "^example.com:" + port + " example.com$"},
+				},
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			pool := x509.NewCertPool()
+			pool.AddCert(server.Certificate())
+			client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Fatalf("statusCode = %d, want %d (err = %v, assertErr = %v)", result.StatusCode, tt.wantStatus, result.Err, result.AssertErr)
+			}
+			if result.StatusCode > 0 && result.RemoteIP != "127.0.0.1" {
+				t.Errorf("remoteIP = %q, want 127.0.0.1", result.RemoteIP)
+			}
+			if got := config.templateData(result)["remoteIP"]; got != result.RemoteIP {
+				t.Errorf("templateData()[remoteIP] = %v", got)
+			}
+		})
+	}
+}
+
+// serveDNS は A レコードの問い合わせに ips を返す UDP の DNS サーバーを起動し、アドレスを返します
+func serveDNS(t *testing.T, ips ...net.IP) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			query := buf[:n]
+			if len(query) < 12 {
+				continue
+			}
+			// 質問部の終わり(QNAME の後の QTYPE・QCLASS)を探します
+			end := 12
+			for end < len(query) && query[end] != 0 {
+				end += int(query[end]) + 1
+			}
+			end += 5
+			if end > len(query) {
+				continue
+			}
+			qtype := binary.BigEndian.Uint16(query[end-4:])
+
+			resp := append([]byte(nil), query[:end]...)
+			resp[2] = 0x81 // QR, RD
+			resp[3] = 0x80 // RA, NOERROR
+			binary.BigEndian.PutUint16(resp[6:], 0)
+			binary.BigEndian.PutUint16(resp[8:], 0)
+			binary.BigEndian.PutUint16(resp[10:], 0)
+			if qtype == 1 {
+				binary.BigEndian.PutUint16(resp[6:], uint16(len(ips)))
+				for _, ip := range ips {
+					resp = append(resp, 0xc0, 12) // 質問の名前を参照
+					resp = binary.BigEndian.AppendUint16(resp, 1)
+					resp = binary.BigEndian.AppendUint16(resp, 1)
+					resp = binary.BigEndian.AppendUint32(resp, 60)
+					resp = binary.BigEndian.AppendUint16(resp, 4)
+					resp = append(resp, ip.To4()...)
+				}
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestDNSServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	}))
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	config := &Config{
+		URL:      "http://monitor.invalid:" + port,
+		Interval: time.Second,
+		Timeout:  TimeoutConfig{Connect: 2 * time.Second, Read: time.Second},
+		DNS:      DNSConfig{Server: serveDNS(t, net.ParseIP("127.0.0.1")), IPVersion: 4},
+		Asserts: AssertsConfig{
+			StatusCode: StatusCodeAssert{Values: []int{200}},
+			Body:       BodyAssert{Regex: "^monitor.invalid:" + port + "$"},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	result, err := checkOnce(context.Background(), config, client)
+	if err != nil {
+		t.Fatalf("checkOnce() error = %v", err)
+	}
+	if !result.Success() {
+		t.Fatalf("statusCode = %d (err = %v, assertErr = %v)", result.StatusCode, result.Err, result.AssertErr)
+	}
+	if result.RemoteIP != "127.0.0.1" {
+		t.Errorf("remoteIP = %q, want 127.0.0.1", result.RemoteIP)
+	}
+	if record := config.newJSONRecord(result); !strings.Contains(formatJSONValue(record), `"remote_ip":"127.0.0.1"`) {
+		t.Errorf("record = %+v", record)
+	}
+}
@@ -0,0 +1,227 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+)
+
+// fan_out に指定できる値
+const (
+	FanOutAllIPs = "all_ips"
+)
+
+// targetKey は結果を集計する単位です。fan_out のターゲットでは IP ごとに別の状態を持ちます
+type targetKey struct {
+	target *Config
+	ip     string
+}
+
+// displayName は "api (10.0.0.5)" のように IP を付けたターゲット名です
+func (k targetKey) displayName() string {
+	if k.ip == "" {
+		return k.target.targetName()
+	}
+	return k.target.targetName() + " (" + k.ip + ")"
+}
+
+// orderedTargetKeys は keys を設定のターゲット順、IP 順に並べます。
+// fan_out のターゲットに IP ごとの結果がある場合、結果の無いターゲット自体のキー(名前解決の失敗用)は除きます
+func orderedTargetKeys(targets []*Config, keys []targetKey, observed func(targetKey) bool) []targetKey {
+	index := make(map[*Config]int, len(targets))
+	for i, target := range targets {
+		index[target] = i
+	}
+	hasIPs := make(map[*Config]bool)
+	for _, key := range keys {
+		if key.ip != "" {
+			hasIPs[key.target] = true
+		}
+	}
+
+	ordered := make([]targetKey, 0, len(keys))
+	for _, key := range keys {
+		if key.ip == "" && hasIPs[key.target] && !observed(key) {
+			continue
+		}
+		ordered = append(ordered, key)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		if ordered[i].target != ordered[j].target {
+			return index[ordered[i].target] < index[ordered[j].target]
+		}
+		return ordered[i].ip < ordered[j].ip
+	})
+	return ordered
+}
+
+// fanOut は all_ips モードで IP ごとのクライアントを保持します。接続先を固定する以外は元のクライアントと同じ設定です
+type fanOut struct {
+	dialer  *overrideDialer
+	client  *http.Client
+	clients map[string]*http.Client
+}
+
+func newFanOut(config *Config, client *http.Client) *fanOut {
+	return &fanOut{
+		dialer:  config.newDialer(),
+		client:  client,
+		clients: make(map[string]*http.Client),
+	}
+}
+
+// clientFor は hostPort への接続だけを ip に向けるクライアントを返します。
+// URL は変えないため Host ヘッダーと SNI は元のホスト名のままです
+func (f *fanOut) clientFor(hostPort, ip string) *http.Client {
+	if client, ok := f.clients[ip]; ok {
+		return client
+	}
+
+	_, port, _ := net.SplitHostPort(hostPort)
+	pinned := net.JoinHostPort(ip, port)
+	transport := f.client.Transport.(*http.Transport).Clone()
+	// プロキシを経由すると接続先の IP を選べないため直接接続します
+	transport.Proxy = nil
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if addr == hostPort {
+			return f.dialer.dialer.DialContext(ctx, network, pinned)
+		}
+		return f.dialer.DialContext(ctx, network, addr)
+	}
+
+	client := *f.client
+	client.Transport = transport
+	f.clients[ip] = &client
+	return &client
+}
+
+// prune は名前解決の結果から外れた IP のクライアントを接続ごと破棄します
+func (f *fanOut) prune(ips []string) {
+	current := make(map[string]bool, len(ips))
+	for _, ip := range ips {
+		current[ip] = true
+	}
+	for ip, client := range f.clients {
+		if !current[ip] {
+			client.CloseIdleConnections()
+			delete(f.clients, ip)
+		}
+	}
+}
+
+// closeIdleConnections は keep_alive で保持している IP ごとの接続を閉じます
+func (f *fanOut) closeIdleConnections() {
+	for _, client := range f.clients {
+		client.CloseIdleConnections()
+	}
+}
+
+// lookup は resolve の上書きを考慮してホストの IP アドレスをすべて返します
+func (f *fanOut) lookup(ctx context.Context, host, port string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{host}, nil
+	}
+	if addr := f.dialer.override(net.JoinHostPort(host, port)); addr != net.JoinHostPort(host, port) {
+		ip, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		return []string{ip}, nil
+	}
+
+	resolver := f.dialer.dialer.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupIP(ctx, f.dialer.dns.network("ip"), host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(ips))
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		if !seen[ip.String()] {
+			seen[ip.String()] = true
+			addrs = append(addrs, ip.String())
+		}
+	}
+	return addrs, nil
+}
+
+// check はホストを名前解決し、すべての IP に並行してリクエストを送ります。
+// 名前解決に失敗した場合は IP の無い結果を1つ返します
+func (f *fanOut) check(ctx context.Context, config *Config) ([]*Result, error) {
+	targetURL, err := url.Parse(config.URL)
+	if err != nil {
+		return nil, err
+	}
+	port := targetURL.Port()
+	if port == "" {
+		port = "80"
+		if targetURL.Scheme == "https" {
+			port = "443"
+		}
+	}
+	hostPort := net.JoinHostPort(targetURL.Hostname(), port)
+
+	result := &Result{
+		Target:      config.targetName(),
+		RequestedAt: time.Now(),
+		URL:         config.URL,
+	}
+	// 通常のリクエストの net.Dialer と同じく、timeout.connect が 0 の場合は名前解決の時間を制限しません
+	lookupCtx := ctx
+	if config.Timeout.Connect > 0 {
+		var cancel context.CancelFunc
+		lookupCtx, cancel = context.WithTimeout(ctx, config.Timeout.Connect)
+		defer cancel()
+	}
+	ips, err := f.lookup(lookupCtx, targetURL.Hostname(), port)
+	if err != nil {
+		result.Duration = time.Since(result.RequestedAt)
+		result.Err = err
+		// 名前解決は接続の一部として timeout.connect で区切っているため、タイムアウトは接続のタイムアウトとして扱います
+		result.StatusCode = getRequestErrorStatus(err, phaseConnect)
+		return []*Result{result}, nil
+	}
+	if len(ips) == 0 {
+		result.Duration = time.Since(result.RequestedAt)
+		result.Err = fmt.Errorf("no addresses found for %s", targetURL.Hostname())
+		result.StatusCode = StatusDNSLookupFailed
+		return []*Result{result}, nil
+	}
+
+	// DNS のレコードが入れ替わっても、使われなくなった IP のクライアントが残り続けないようにします
+	f.prune(ips)
+	clients := make([]*http.Client, len(ips))
+	for i, ip := range ips {
+		clients[i] = f.clientFor(hostPort, ip)
+	}
+
+	results := make([]*Result, len(ips))
+	errs := make([]error, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		wg.Add(1)
+		go func(i int, ip string) {
+			defer wg.Done()
+			results[i], errs[i] = checkRequest(ctx, config, clients[i])
+			if results[i] != nil {
+				results[i].IP = ip
+			}
+		}(i, ip)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,285 @@
+package checker
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newNodesServer は全てのアドレスで待ち受け、127.0.0.2 で受けたリクエストだけ失敗するサーバーです
+func newNodesServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp4", "0.0.0.0:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		local := r.Context().Value(http.LocalAddrContextKey).(net.Addr).String()
+		if strings.HasPrefix(local, "127.0.0.2:") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte(r.Host))
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return server, port
+}
+
+func TestFanOutCheck(t *testing.T) {
+	_, port := newNodesServer(t)
+
+	config := &Config{
+		Name:     "nodes",
+		URL:      "http://nodes.invalid:" + port,
+		FanOut:   FanOutAllIPs,
+		Interval: time.Second,
+		Timeout:  TimeoutConfig{Connect: 2 * time.Second, Read: time.Second},
+		DNS:      DNSConfig{Server: serveDNS(t, net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1"))},
+		Asserts: AssertsConfig{
+			StatusCode: StatusCodeAssert{Values: []int{200}},
+			// Host ヘッダーは URL のホスト名のままです
+			Body: BodyAssert{Regex: "^nodes.invalid:" + port + "$"},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+
+	fan := newFanOut(config, client)
+	results, err := fan.check(context.Background(), config)
+	if err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("len(results) = %d, want 2", len(results))
+	}
+	got := map[string]int{}
+	for _, result := range results {
+		got[result.IP] = result.StatusCode
+		if result.RemoteIP != result.IP {
+			t.Errorf("remoteIP = %q, want %q", result.RemoteIP, result.IP)
+		}
+	}
+	if got["127.0.0.1"] != 200 || got["127.0.0.2"] != StatusAssertFailed {
+		t.Errorf("status by ip = %v", got)
+	}
+
+	// 集計は IP ごとに分かれます
+	s := NewSummary(config)
+	m := newMetrics([]*Config{config})
+	for _, result := range results {
+		s.Write(config, result)
+		m.Write(config, result)
+	}
+	var summaryBuf, metricsBuf strings.Builder
+	s.Print(&summaryBuf)
+	m.render(&metricsBuf)
+	for _, want := range []string{
+		"--- nodes (127.0.0.1) statistics ---\n1 checks, 1 succeeded",
+		"--- nodes (127.0.0.2) statistics ---\n1 checks, 0 succeeded",
+	} {
+		if !strings.Contains(summaryBuf.String(), want) {
+			t.Errorf("summary missing %q:\n%s", want, summaryBuf.String())
+		}
+	}
+	if strings.Contains(summaryBuf.String(), "--- nodes statistics ---") {
+		t.Errorf("summary has an empty block for the target itself:\n%s", summaryBuf.String())
+	}
+	for _, want := range []string{
+		`chechekule_up{target="nodes",ip="127.0.0.1"} 1`,
+		`chechekule_up{target="nodes",ip="127.0.0.2"} 0`,
+	} {
+		if !strings.Contains(metricsBuf.String(), want) {
+			t.Errorf("metrics missing %q:\n%s", want, metricsBuf.String())
+		}
+	}
+	if record := config.newJSONRecord(results[0]); record.IP == "" {
+		t.Errorf("record.IP is empty")
+	}
+}
+
+func TestFanOutResolve(t *testing.T) {
+	_, port := newNodesServer(t)
+
+	// 問い合わせに応答しない DNS サーバー
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer silent.Close()
+
+	tests := []struct {
+		name       string
+		config     Config
+		connect    time.Duration
+		wantIPs    []string
+		wantStatus int
+	}{
+		{
+			name: "resolve override",
+			config: Config{
+				URL:     "http://nodes.invalid:" + port,
+				Resolve: map[string]string{"nodes.invalid": "127.0.0.1"},
+			},
+			wantIPs:    []string{"127.0.0.1"},
+			wantStatus: 200,
+		},
+		{
+			name: "ip literal",
+			config: Config{
+				URL: "http://127.0.0.2:" + port,
+			},
+			wantIPs:    []string{"127.0.0.2"},
+			wantStatus: 503,
+		},
+		{
+			name: "lookup failure",
+			config: Config{
+				URL: "http://nodes.invalid:" + port,
+				DNS: DNSConfig{Server: serveDNS(t)},
+			},
+			wantIPs:    []string{""},
+			wantStatus: StatusDNSLookupFailed,
+		},
+		{
+			name: "lookup timeout",
+			config: Config{
+				URL: "http://nodes.invalid:" + port,
+				DNS: DNSConfig{Server: silent.LocalAddr().String()},
+			},
+			connect:    200 * time.Millisecond,
+			wantIPs:    []string{""},
+			wantStatus: StatusConnectTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &tt.config
+			config.FanOut = FanOutAllIPs
+			config.Timeout = TimeoutConfig{Connect: 2 * time.Second, Read: time.Second}
+			if tt.connect > 0 {
+				config.Timeout.Connect = tt.connect
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			results, err := newFanOut(config, client).check(context.Background(), config)
+			if err != nil {
+				t.Fatalf("check() error = %v", err)
+			}
+			if len(results) != len(tt.wantIPs) {
+				t.Fatalf("len(results) = %d, want %d", len(results), len(tt.wantIPs))
+			}
+			for i, result := range results {
+				if result.IP != tt.wantIPs[i] {
+					t.Errorf("ip = %q, want %q", result.IP, tt.wantIPs[i])
+				}
+				status := result.StatusCode
+				if result.Response != nil {
+					status = result.Response.StatusCode
+				}
+				if status != tt.wantStatus {
+					t.Errorf("status = %d, want %d (err = %v)", status, tt.wantStatus, result.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestFanOutNoConnectTimeout(t *testing.T) {
+	_, port := newNodesServer(t)
+
+	// timeout.connect が 0 でも名前解決がすぐにタイムアウトしないこと
+	config := &Config{
+		URL:      "http://nodes.invalid:" + port,
+		FanOut:   FanOutAllIPs,
+		Interval: time.Second,
+		DNS:      DNSConfig{Server: serveDNS(t, net.ParseIP("127.0.0.1"))},
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	results, err := newFanOut(config, client).check(context.Background(), config)
+	if err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if len(results) != 1 || results[0].StatusCode != http.StatusOK {
+		t.Errorf("results = %+v, want 200 from 127.0.0.1", results[0])
+	}
+}
+
+func TestFanOutPrunesClients(t *testing.T) {
+	_, port := newNodesServer(t)
+
+	config := &Config{
+		URL:     "http://127.0.0.1:" + port,
+		FanOut:  FanOutAllIPs,
+		Timeout: TimeoutConfig{Connect: 2 * time.Second, Read: time.Second},
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+
+	// 前回の名前解決で返ったが、今回は返らなかった IP のクライアントは破棄すること
+	fan := newFanOut(config, client)
+	fan.clientFor("127.0.0.1:"+port, "127.0.0.2")
+	if _, err := fan.check(context.Background(), config); err != nil {
+		t.Fatalf("check() error = %v", err)
+	}
+	if _, ok := fan.clients["127.0.0.2"]; ok || len(fan.clients) != 1 {
+		t.Errorf("clients = %v, want only 127.0.0.1", fan.clients)
+	}
+}
+
+func TestFanOutRunCheck(t *testing.T) {
+	_, port := newNodesServer(t)
+
+	config := &Config{
+		Name:     "nodes",
+		URL:      "http://nodes.invalid:" + port,
+		FanOut:   FanOutAllIPs,
+		Interval: 50 * time.Millisecond,
+		Count:    2,
+		Timeout:  TimeoutConfig{Connect: 2 * time.Second, Read: time.Second},
+		DNS:      DNSConfig{Server: serveDNS(t, net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2"))},
+		Asserts:  AssertsConfig{StatusCode: StatusCodeAssert{Values: []int{200}}},
+	}
+
+	// 1つの IP が失敗していればチェックは失敗です
+	if err := runChecker(t, context.Background(), config); err == nil {
+		t.Errorf("Run() expected error when one IP fails")
+	}
+
+	config.Resolve = map[string]string{"nodes.invalid": "127.0.0.1"}
+	if err := runChecker(t, context.Background(), config); err != nil {
+		t.Errorf("Run() error = %v", err)
+	}
+}
+
+func TestFanOutValidate(t *testing.T) {
+	tests := []Config{
+		{URL: "http://example.com", Interval: time.Second, FanOut: "random"},
+		{Interval: time.Second, FanOut: FanOutAllIPs, Steps: []StepConfig{{URL: "http://example.com"}}},
+		{URL: "http://example.com", Interval: time.Second, FanOut: FanOutAllIPs, Proxy: ProxyConfig{URL: "http://proxy.example.com"}},
+	}
+	for _, config := range tests {
+		if err := config.Validate(); err == nil {
+			t.Errorf("validate(%+v) expected error", config)
+		}
+	}
+}
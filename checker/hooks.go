@@ -0,0 +1,306 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// フックのイベント名
+const (
+	HookEventFailure  = "failure"
+	HookEventRecovery = "recovery"
+	HookEventEach     = "each"
+	HookEventStop     = "stop"
+)
+
+// hookPayload はフックの標準入力に渡す JSON です
+type hookPayload struct {
+	Event               string `json:"event"`
+	OutageStart         string `json:"outage_start,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	*jsonRecord
+}
+
+// newHookPayload は状態と最後のチェック結果からフックや通知に渡す内容を作成します
+func newHookPayload(target *Config, event string, tracker *stateTracker, last *Result) hookPayload {
+	payload := hookPayload{
+		Event:               event,
+		ConsecutiveFailures: tracker.consecutiveFailures,
+	}
+	if tracker.down || event == HookEventRecovery {
+		payload.OutageStart = tracker.outageStart.Format("2006-01-02T15:04:05.000Z07:00")
+	}
+	if last != nil {
+		record := target.newJSONRecord(last)
+		payload.jsonRecord = &record
+	}
+	return payload
+}
+
+type targetHookState struct {
+	tracker *stateTracker
+	last    *Result
+}
+
+func newTargetHookState(target *Config) *targetHookState {
+	return &targetHookState{
+		tracker: newStateTracker(target.Hooks.FailureThreshold, target.Hooks.RecoveryThreshold),
+	}
+}
+
+// hookJob は実行を待っているフックです
+type hookJob struct {
+	path    string
+	event   string
+	env     []string
+	payload hookPayload
+}
+
+// hookQueue はターゲットのフックを1つずつ実行するためのキューです。
+// on_failure / on_recovery / on_stop は発生した順に、on_each より先に実行します。
+// on_each は待っているものを最新の結果の1つにまとめ、フックが間隔より遅くてもキューが伸び続けないようにします
+type hookQueue struct {
+	jobs    []hookJob
+	each    *hookJob
+	running bool
+}
+
+// next は次に実行するフックを取り出します
+func (q *hookQueue) next() (hookJob, bool) {
+	if len(q.jobs) > 0 {
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		return job, true
+	}
+	if q.each != nil {
+		job := *q.each
+		q.each = nil
+		return job, true
+	}
+	return hookJob{}, false
+}
+
+// HookSink はチェック結果に応じて on_failure / on_recovery / on_each / on_stop のフックを実行します。
+// fan_out のターゲットは IP ごとに up/down を判定します
+type HookSink struct {
+	mu      sync.Mutex
+	config  *Config
+	targets []*Config
+	states  map[targetKey]*targetHookState
+	queues  map[*Config]*hookQueue
+	wg      sync.WaitGroup
+
+	// errOut には Close と複数ターゲットのフックの goroutine から書き込むため、errMu で書き込みを直列化します
+	errMu  sync.Mutex
+	errOut io.Writer
+
+	// ctx は Close の期限を過ぎたときに実行中のフックを止めるために使います
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// NewHookSink は config のターゲットのフックを実行する HookSink を作成します。フックの失敗は errOut に書き出します
+func NewHookSink(config *Config, errOut io.Writer) *HookSink {
+	h := &HookSink{
+		config:  config,
+		targets: config.CheckTargets(),
+		states:  make(map[targetKey]*targetHookState),
+		queues:  make(map[*Config]*hookQueue),
+		errOut:  errOut,
+	}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	for _, target := range h.targets {
+		h.states[targetKey{target: target}] = newTargetHookState(target)
+		h.queues[target] = &hookQueue{}
+	}
+	return h
+}
+
+// Start は on_start のフックを実行し、終了を待ちます
+func (h *HookSink) Start() {
+	if h.config.Hooks.OnStart == "" {
+		return
+	}
+	if err := executeHook(h.ctx, h.config.Hooks.OnStart, h.config.Hooks.timeout(), nil, nil); err != nil {
+		h.errorf(nil, "Failed to execute hook: %v\n", err)
+	}
+}
+
+func (h *HookSink) Write(target *Config, result *Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.states[targetKey{target: target}]; !ok {
+		return
+	}
+	key := targetKey{target: target, ip: result.IP}
+	state, ok := h.states[key]
+	if !ok {
+		state = newTargetHookState(target)
+		h.states[key] = state
+	}
+	state.last = result
+
+	switch state.tracker.update(result) {
+	case transitionDown:
+		h.run(target, target.Hooks.OnFailure, HookEventFailure, state)
+	case transitionUp:
+		h.run(target, target.Hooks.OnRecovery, HookEventRecovery, state)
+	}
+	h.run(target, target.Hooks.OnEach, HookEventEach, state)
+}
+
+// Close は最後の結果の on_each の後に各ターゲットの on_stop を実行し、キューのフックがすべて終わるまで待ちます。
+// 実行中のフック・on_each・on_stop の分として最も長い hooks.timeout の3倍を過ぎても終わらない場合は、実行中のフックを止めて残りを破棄します
+func (h *HookSink) Close() {
+	h.mu.Lock()
+	for _, queue := range h.queues {
+		if queue.each != nil {
+			queue.jobs = append(queue.jobs, *queue.each)
+			queue.each = nil
+		}
+	}
+	keys := make([]targetKey, 0, len(h.states))
+	for key := range h.states {
+		keys = append(keys, key)
+	}
+	keys = orderedTargetKeys(h.targets, keys, func(key targetKey) bool {
+		return h.states[key].last != nil
+	})
+	for _, key := range keys {
+		h.run(key.target, key.target.Hooks.OnStop, HookEventStop, h.states[key])
+	}
+	h.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(h.closeTimeout())
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		h.errorf(nil, "Hooks did not finish within %v, stopping them\n", h.closeTimeout())
+		h.cancel()
+		<-done
+	}
+	h.cancel()
+}
+
+// errorf はターゲット名を付けて errOut に書き出します。target が nil の場合は名前を付けません
+func (h *HookSink) errorf(target *Config, format string, args ...interface{}) {
+	h.errMu.Lock()
+	defer h.errMu.Unlock()
+	if target == nil {
+		fmt.Fprintf(h.errOut, format, args...)
+		return
+	}
+	target.errorf(h.errOut, format, args...)
+}
+
+// closeTimeout は Close で待つ時間です。ターゲットごとの hooks.timeout のうち最も長いものの、実行中のフック・on_each・on_stop の3回分です
+func (h *HookSink) closeTimeout() time.Duration {
+	timeout := h.config.Hooks.timeout()
+	for _, target := range h.targets {
+		timeout = max(timeout, target.Hooks.timeout())
+	}
+	return 3 * timeout
+}
+
+// run はチェックのループを止めないよう、フックをターゲットのキューに追加して別の goroutine で実行します。h.mu を保持して呼び出します
+func (h *HookSink) run(target *Config, path string, event string, state *targetHookState) {
+	if path == "" {
+		return
+	}
+
+	payload := newHookPayload(target, event, state.tracker, state.last)
+
+	env := []string{
+		"CHECHEKULE_EVENT=" + event,
+		"CHECHEKULE_TARGET=" + target.targetName(),
+		"CHECHEKULE_OUTAGE_START=" + payload.OutageStart,
+		"CHECHEKULE_CONSECUTIVE_FAILURES=" + strconv.Itoa(payload.ConsecutiveFailures),
+	}
+	if result := state.last; result != nil {
+		record := payload.jsonRecord
+		env = append(env,
+			"CHECHEKULE_IP="+record.IP,
+			"CHECHEKULE_URL="+record.URL,
+			"CHECHEKULE_REQUESTED_AT="+record.Timestamp,
+			"CHECHEKULE_STATUS_CODE="+strconv.Itoa(record.StatusCode),
+			"CHECHEKULE_ERROR="+record.Error,
+			"CHECHEKULE_ERROR_MESSAGE="+record.ErrorMessage,
+			"CHECHEKULE_ASSERT_FAILURE="+record.AssertFailure,
+			"CHECHEKULE_DURATION="+result.Duration.String(),
+		)
+	}
+
+	queue := h.queues[target]
+	job := hookJob{path: path, event: event, env: env, payload: payload}
+	if event == HookEventEach {
+		queue.each = &job
+	} else {
+		queue.jobs = append(queue.jobs, job)
+	}
+	if !queue.running {
+		queue.running = true
+		h.wg.Add(1)
+		go h.drain(target, queue)
+	}
+}
+
+// drain はキューが空になるまでフックを順に実行します。Close の期限を過ぎた場合は残りを破棄します
+func (h *HookSink) drain(target *Config, queue *hookQueue) {
+	defer h.wg.Done()
+	for {
+		h.mu.Lock()
+		job, ok := queue.next()
+		if !ok || h.ctx.Err() != nil {
+			queue.jobs = nil
+			queue.each = nil
+			queue.running = false
+			h.mu.Unlock()
+			return
+		}
+		h.mu.Unlock()
+
+		input, err := json.Marshal(job.payload)
+		if err == nil {
+			err = executeHook(h.ctx, job.path, target.Hooks.timeout(), job.env, input)
+		} else {
+			err = fmt.Errorf("failed to marshal hook payload: %w", err)
+		}
+		if err != nil {
+			h.errorf(target, "Failed to execute %s hook: %v\n", job.event, err)
+		}
+	}
+}
+
+// executeHook はチェック結果を環境変数と標準入力の JSON で渡してフックを実行します。timeout を過ぎたフックは強制終了します
+func executeHook(ctx context.Context, path string, timeout time.Duration, env []string, input []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Env = append(os.Environ(), env...)
+	// フックが起動した子プロセスが標準入出力を握ったままでも待ち続けないようにします
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %v: %w", timeout, err)
+	}
+	return err
+}
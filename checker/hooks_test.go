@@ -0,0 +1,258 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// writeHookScript は環境変数と標準入力をイベントごとのファイルに書き出すスクリプトを作成します
+func writeHookScript(t *testing.T, dir string) string {
+	t.Helper()
+	scriptPath := filepath.Join(dir, "hook.sh")
+	script := `#!/bin/sh
+cat > "` + dir + `/$CHECHEKULE_EVENT.json"
+echo "$CHECHEKULE_TARGET $CHECHEKULE_STATUS_CODE $CHECHEKULE_ERROR $CHECHEKULE_OUTAGE_START" > "` + dir + `/$CHECHEKULE_EVENT.env"
+echo "$CHECHEKULE_STATUS_CODE" >> "` + dir + `/each.log"
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write hook script: %v", err)
+	}
+	return scriptPath
+}
+
+func TestStateHooks(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := writeHookScript(t, tmpDir)
+
+	// 1回目は成功、2〜4回目は失敗、5回目以降は成功
+	var mu sync.Mutex
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		n := hits
+		mu.Unlock()
+		if n >= 2 && n <= 4 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		Name:     "api",
+		URL:      server.URL,
+		Interval: 50 * time.Millisecond,
+		Count:    6,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Asserts: AssertsConfig{
+			StatusCode: StatusCodeAssert{Values: []int{200}},
+		},
+		Hooks: HooksConfig{
+			OnFailure:         scriptPath,
+			OnRecovery:        scriptPath,
+			OnStop:            scriptPath,
+			FailureThreshold:  2,
+			RecoveryThreshold: 2,
+		},
+	}
+
+	hooks := NewHookSink(config, os.Stderr)
+	runChecker(t, context.Background(), config, hooks)
+	hooks.Close()
+
+	failureEnv, err := os.ReadFile(filepath.Join(tmpDir, "failure.env"))
+	if err != nil {
+		t.Fatalf("on_failure was not executed: %v", err)
+	}
+	if !strings.HasPrefix(string(failureEnv), "api -5 ASSERT_FAILED 20") {
+		t.Errorf("failure env = %q", failureEnv)
+	}
+
+	var payload map[string]interface{}
+	failureJSON, _ := os.ReadFile(filepath.Join(tmpDir, "failure.json"))
+	if err := json.Unmarshal(failureJSON, &payload); err != nil {
+		t.Fatalf("Failed to parse failure payload %s: %v", failureJSON, err)
+	}
+	if payload["event"] != HookEventFailure || payload["url"] != server.URL || payload["consecutive_failures"] != float64(2) {
+		t.Errorf("failure payload = %v", payload)
+	}
+	if payload["outage_start"] == nil {
+		t.Errorf("failure payload should have outage_start: %v", payload)
+	}
+
+	recoveryEnv, err := os.ReadFile(filepath.Join(tmpDir, "recovery.env"))
+	if err != nil {
+		t.Fatalf("on_recovery was not executed: %v", err)
+	}
+	if !strings.HasPrefix(string(recoveryEnv), "api 200  20") {
+		t.Errorf("recovery env = %q", recoveryEnv)
+	}
+
+	if _, err := os.ReadFile(filepath.Join(tmpDir, "stop.env")); err != nil {
+		t.Errorf("on_stop was not executed: %v", err)
+	}
+
+	each, _ := os.ReadFile(filepath.Join(tmpDir, "each.log"))
+	if got := strings.Count(string(each), "\n"); got != 3 {
+		t.Errorf("hooks executed %d times, want 3 (failure, recovery, stop)", got)
+	}
+}
+
+func TestEachHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := writeHookScript(t, tmpDir)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 50 * time.Millisecond,
+		Count:    3,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Hooks: HooksConfig{
+			OnEach: scriptPath,
+		},
+	}
+
+	hooks := NewHookSink(config, os.Stderr)
+	err := runChecker(t, context.Background(), config, hooks)
+	hooks.Close()
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	each, err := os.ReadFile(filepath.Join(tmpDir, "each.log"))
+	if err != nil {
+		t.Fatalf("on_each was not executed: %v", err)
+	}
+	if got := string(each); got != "200\n200\n200\n" {
+		t.Errorf("each.log = %q, want three 200 lines", got)
+	}
+}
+
+// writeSlowHookScript はイベント名とステータスコードを記録し、on_each の場合だけ sleep 秒眠るスクリプトを作成します
+func writeSlowHookScript(t *testing.T, dir string, sleep string) string {
+	t.Helper()
+	scriptPath := filepath.Join(dir, "slow.sh")
+	script := `#!/bin/sh
+echo "$CHECHEKULE_EVENT $CHECHEKULE_STATUS_CODE" >> "` + dir + `/events.log"
+if [ "$CHECHEKULE_EVENT" = each ]; then exec sleep ` + sleep + `; fi
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write hook script: %v", err)
+	}
+	return scriptPath
+}
+
+// waitForFile は path に want が書き込まれるまで待ちます
+func waitForFile(t *testing.T, path string, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if content, _ := os.ReadFile(path); strings.Contains(string(content), want) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("%s does not contain %q", path, want)
+}
+
+func TestHookOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := writeSlowHookScript(t, tmpDir, "0.3")
+	eventsPath := filepath.Join(tmpDir, "events.log")
+
+	target := &Config{
+		Name: "api",
+		URL:  "https://example.com",
+		Hooks: HooksConfig{
+			OnFailure:  scriptPath,
+			OnRecovery: scriptPath,
+			OnEach:     scriptPath,
+			OnStop:     scriptPath,
+		},
+	}
+	var errOut bytes.Buffer
+	h := NewHookSink(target, &errOut)
+	h.Write(target, &Result{StatusCode: 200})
+	waitForFile(t, eventsPath, "each 200\n")
+
+	// on_each が間隔より遅くても、待っている on_each は最新の1つにまとめること
+	for _, code := range []int{StatusAssertFailed, StatusAssertFailed, StatusAssertFailed, 200} {
+		h.Write(target, &Result{StatusCode: code})
+	}
+	h.mu.Lock()
+	queue := h.queues[target]
+	if len(queue.jobs) != 2 || queue.each == nil || queue.each.payload.StatusCode != 200 {
+		t.Errorf("queue = %d jobs, each = %+v, want failure and recovery with the latest on_each", len(queue.jobs), queue.each)
+	}
+	h.mu.Unlock()
+	h.Close()
+
+	// 状態の変化のフックは発生した順に、待っている on_each より先に実行し、on_stop は最後の on_each の後に実行すること
+	events, err := os.ReadFile(eventsPath)
+	if err != nil {
+		t.Fatalf("hooks were not executed: %v", err)
+	}
+	want := "each 200\nfailure -5\nrecovery 200\neach 200\nstop 200\n"
+	if string(events) != want {
+		t.Errorf("events.log = %q, want %q", events, want)
+	}
+	if errOut.Len() > 0 {
+		t.Errorf("errOut = %q, want empty", errOut.String())
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	tmpDir := t.TempDir()
+	scriptPath := writeSlowHookScript(t, tmpDir, "5")
+	eventsPath := filepath.Join(tmpDir, "events.log")
+
+	target := &Config{
+		Name: "api",
+		URL:  "https://example.com",
+		Hooks: HooksConfig{
+			OnEach:  scriptPath,
+			OnStop:  scriptPath,
+			Timeout: 200 * time.Millisecond,
+		},
+	}
+	var errOut bytes.Buffer
+	h := NewHookSink(target, &errOut)
+	h.Write(target, &Result{StatusCode: 200})
+	waitForFile(t, eventsPath, "each 200\n")
+
+	// タイムアウトしたフックは止め、その後に on_stop を実行すること
+	start := time.Now()
+	h.Close()
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Close took %v, want the hook to be stopped after hooks.timeout", elapsed)
+	}
+	if !strings.Contains(errOut.String(), "[api] Failed to execute each hook: timed out after 200ms") {
+		t.Errorf("errOut = %q, want a timeout error", errOut.String())
+	}
+	events, _ := os.ReadFile(eventsPath)
+	if string(events) != "each 200\nstop 200\n" {
+		t.Errorf("events.log = %q, want on_each and on_stop", events)
+	}
+}
@@ -0,0 +1,107 @@
+package checker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseJSONPath は "$.data.items[0].name" や "data.items.0.name"、`$["key.with.dot"]` のようなパスを要素に分解します。
+// 数値の要素は配列の添字としても解釈されます
+func parseJSONPath(path string) ([]string, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(path), "$")
+	var segments []string
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid json path %q: empty segment", path)
+			}
+			segments = append(segments, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q: missing ]", path)
+			}
+			key := p[1:end]
+			if unquoted, err := strconv.Unquote(key); err == nil {
+				key = unquoted
+			} else if strings.HasPrefix(key, "'") && strings.HasSuffix(key, "'") && len(key) >= 2 {
+				key = key[1 : len(key)-1]
+			}
+			segments = append(segments, key)
+			p = p[end+1:]
+		default:
+			// 先頭の "$." を省略した形式
+			p = "." + p
+		}
+	}
+	return segments, nil
+}
+
+// lookupJSONPath は JSON の値からパスが指す値を取り出します。見つからない場合は found が false になります
+func lookupJSONPath(data interface{}, path string) (value interface{}, found bool, err error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value = data
+	for _, segment := range segments {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			child, ok := v[segment]
+			if !ok {
+				return nil, false, nil
+			}
+			value = child
+		case []interface{}:
+			index, err := strconv.Atoi(segment)
+			if err != nil {
+				return nil, false, nil
+			}
+			if index < 0 {
+				index += len(v)
+			}
+			if index < 0 || index >= len(v) {
+				return nil, false, nil
+			}
+			value = v[index]
+		default:
+			return nil, false, nil
+		}
+	}
+	return value, true, nil
+}
+
+// decodeJSONBody はレスポンスボディを JSON としてデコードします。数値は精度を保つため json.Number のままにします
+func decodeJSONBody(body []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var data interface{}
+	if err := decoder.Decode(&data); err != nil {
+		return nil, fmt.Errorf("response body is not valid JSON: %w", err)
+	}
+	return data, nil
+}
+
+// jsonValueString は抽出した値を文字列にします。文字列はそのまま、それ以外は JSON 表現になります
+func jsonValueString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
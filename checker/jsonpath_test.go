@@ -0,0 +1,58 @@
+package checker
+
+import (
+	"testing"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	data, err := decodeJSONBody([]byte(`{"data":{"items":[{"id":1,"name":"a"},{"id":2.5,"name":"b"}],"a.b":true,"empty":null}}`))
+	if err != nil {
+		t.Fatalf("decodeJSONBody() error = %v", err)
+	}
+
+	tests := []struct {
+		path      string
+		want      string
+		wantFound bool
+	}{
+		{path: "$.data.items[0].name", want: "a", wantFound: true},
+		{path: "data.items.1.id", want: "2.5", wantFound: true},
+		{path: "$.data.items[-1].name", want: "b", wantFound: true},
+		{path: `$.data["a.b"]`, want: "true", wantFound: true},
+		{path: "$.data['a.b']", want: "true", wantFound: true},
+		{path: "$.data.empty", want: "null", wantFound: true},
+		{path: "$.data.items[0]", want: `{"id":1,"name":"a"}`, wantFound: true},
+		{path: "$.data.items[2]", wantFound: false},
+		{path: "$.data.missing", wantFound: false},
+		{path: "$.data.items.name", wantFound: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			value, found, err := lookupJSONPath(data, tt.path)
+			if err != nil {
+				t.Fatalf("lookupJSONPath() error = %v", err)
+			}
+			if found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if found && jsonValueString(value) != tt.want {
+				t.Errorf("value = %q, want %q", jsonValueString(value), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseJSONPathErrors(t *testing.T) {
+	for _, path := range []string{"$.a..b", "$.a[0"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) expected error", path)
+		}
+	}
+}
+
+func TestDecodeJSONBodyInvalid(t *testing.T) {
+	if _, err := decodeJSONBody([]byte("not json")); err == nil {
+		t.Errorf("decodeJSONBody() expected error")
+	}
+}
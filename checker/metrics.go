@@ -0,0 +1,241 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// レイテンシのヒストグラムのバケット(秒)。Prometheus クライアントのデフォルトと同じです
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type targetMetrics struct {
+	checks         map[string]uint64
+	bucketCounts   []uint64
+	latencySum     float64
+	latencyCount   uint64
+	assertFailures uint64
+	lastSuccess    time.Time
+	up             bool
+	observed       bool
+	certNotAfter   time.Time
+	certDays       int
+}
+
+// metricsKey は系列のラベルです。ip は fan_out のターゲットのみ付与します
+type metricsKey struct {
+	target string
+	ip     string
+}
+
+// metrics は /metrics で Prometheus のテキスト形式として公開する集計値です
+type metrics struct {
+	mu      sync.Mutex
+	targets map[metricsKey]*targetMetrics
+	fanOut  map[string]bool
+}
+
+func newTargetMetrics() *targetMetrics {
+	return &targetMetrics{
+		checks:       make(map[string]uint64),
+		bucketCounts: make([]uint64, len(latencyBuckets)),
+	}
+}
+
+func newMetrics(targets []*Config) *metrics {
+	m := &metrics{
+		targets: make(map[metricsKey]*targetMetrics),
+		fanOut:  make(map[string]bool),
+	}
+	for _, target := range targets {
+		// fan_out のターゲットの系列は IP が分かった時点で作成します
+		if target.FanOut != "" {
+			m.fanOut[target.targetName()] = true
+			continue
+		}
+		m.targets[metricsKey{target: target.targetName()}] = newTargetMetrics()
+	}
+	return m
+}
+
+func (m *metrics) Write(target *Config, result *Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := metricsKey{target: target.targetName(), ip: result.IP}
+	tm, ok := m.targets[key]
+	if !ok {
+		if !m.fanOut[key.target] {
+			return
+		}
+		tm = newTargetMetrics()
+		m.targets[key] = tm
+	}
+
+	tm.checks[result.StatusText()]++
+
+	seconds := result.Duration.Seconds()
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			tm.bucketCounts[i]++
+		}
+	}
+	tm.latencySum += seconds
+	tm.latencyCount++
+
+	if result.AssertErr != nil {
+		tm.assertFailures++
+	}
+
+	if result.TLS != nil && !result.TLS.NotAfter.IsZero() {
+		tm.certNotAfter = result.TLS.NotAfter
+		tm.certDays = result.TLS.DaysRemaining
+	}
+
+	tm.observed = true
+	tm.up = result.Success()
+	if tm.up {
+		tm.lastSuccess = result.RequestedAt
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.render(w)
+}
+
+// render は全ターゲットのメトリクスをターゲット名順に書き出します
+func (m *metrics) render(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]metricsKey, 0, len(m.targets))
+	for key := range m.targets {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].target != keys[j].target {
+			return keys[i].target < keys[j].target
+		}
+		return keys[i].ip < keys[j].ip
+	})
+
+	label := func(key metricsKey) string {
+		labels := `target="` + labelValueReplacer.Replace(key.target) + `"`
+		if key.ip != "" {
+			labels += `,ip="` + key.ip + `"`
+		}
+		return labels
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_checks_total Total number of checks by status code or error name.")
+	fmt.Fprintln(w, "# TYPE chechekule_checks_total counter")
+	for _, key := range keys {
+		tm := m.targets[key]
+		statuses := make([]string, 0, len(tm.checks))
+		for status := range tm.checks {
+			statuses = append(statuses, status)
+		}
+		sort.Strings(statuses)
+		for _, status := range statuses {
+			fmt.Fprintf(w, "chechekule_checks_total{%s,status=\"%s\"} %d\n", label(key), status, tm.checks[status])
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_check_duration_seconds Check latency in seconds.")
+	fmt.Fprintln(w, "# TYPE chechekule_check_duration_seconds histogram")
+	for _, key := range keys {
+		tm := m.targets[key]
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "chechekule_check_duration_seconds_bucket{%s,le=\"%s\"} %d\n", label(key), formatFloat(bound), tm.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "chechekule_check_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", label(key), tm.latencyCount)
+		fmt.Fprintf(w, "chechekule_check_duration_seconds_sum{%s} %s\n", label(key), formatFloat(tm.latencySum))
+		fmt.Fprintf(w, "chechekule_check_duration_seconds_count{%s} %d\n", label(key), tm.latencyCount)
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_last_success_timestamp_seconds Unix time of the last successful check.")
+	fmt.Fprintln(w, "# TYPE chechekule_last_success_timestamp_seconds gauge")
+	for _, key := range keys {
+		tm := m.targets[key]
+		if tm.lastSuccess.IsZero() {
+			continue
+		}
+		fmt.Fprintf(w, "chechekule_last_success_timestamp_seconds{%s} %s\n", label(key), formatFloat(float64(tm.lastSuccess.UnixMilli())/1000))
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_up Whether the last check succeeded (1) or failed (0).")
+	fmt.Fprintln(w, "# TYPE chechekule_up gauge")
+	for _, key := range keys {
+		tm := m.targets[key]
+		if !tm.observed {
+			continue
+		}
+		up := 0
+		if tm.up {
+			up = 1
+		}
+		fmt.Fprintf(w, "chechekule_up{%s} %d\n", label(key), up)
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_assert_failures_total Total number of checks that failed an assert.")
+	fmt.Fprintln(w, "# TYPE chechekule_assert_failures_total counter")
+	for _, key := range keys {
+		fmt.Fprintf(w, "chechekule_assert_failures_total{%s} %d\n", label(key), m.targets[key].assertFailures)
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_tls_cert_days_remaining Days until the server certificate seen by the last TLS check expires.")
+	fmt.Fprintln(w, "# TYPE chechekule_tls_cert_days_remaining gauge")
+	for _, key := range keys {
+		tm := m.targets[key]
+		if tm.certNotAfter.IsZero() {
+			continue
+		}
+		fmt.Fprintf(w, "chechekule_tls_cert_days_remaining{%s} %d\n", label(key), tm.certDays)
+	}
+
+	fmt.Fprintln(w, "# HELP chechekule_tls_cert_not_after_timestamp_seconds Unix time when the server certificate expires.")
+	fmt.Fprintln(w, "# TYPE chechekule_tls_cert_not_after_timestamp_seconds gauge")
+	for _, key := range keys {
+		tm := m.targets[key]
+		if tm.certNotAfter.IsZero() {
+			continue
+		}
+		fmt.Fprintf(w, "chechekule_tls_cert_not_after_timestamp_seconds{%s} %d\n", label(key), tm.certNotAfter.Unix())
+	}
+}
+
+// serveMetrics は /metrics を公開する HTTP サーバーを起動し、停止用の関数を返します
+func serveMetrics(addr string, m *metrics) (func(), error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go server.Serve(listener)
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsWrite(t *testing.T) {
+	api := &Config{Name: "api", URL: "https://example.com/api"}
+	top := &Config{URL: "https://example.com"}
+	m := newMetrics([]*Config{api, top})
+
+	successAt := time.Unix(1700000000, 0)
+	m.Write(api, &Result{RequestedAt: successAt, StatusCode: 200, Duration: 30 * time.Millisecond})
+	m.Write(api, &Result{RequestedAt: successAt.Add(time.Second), StatusCode: StatusAssertFailed, Duration: 2 * time.Second, AssertErr: errors.New("mismatch")})
+	m.Write(top, &Result{RequestedAt: successAt, StatusCode: StatusConnectionFailed, Duration: time.Millisecond})
+
+	var buf bytes.Buffer
+	m.render(&buf)
+	got := buf.String()
+
+	for _, want := range []string{
+		`chechekule_checks_total{target="api",status="200"} 1`,
+		`chechekule_checks_total{target="api",status="ASSERT_FAILED"} 1`,
+		`chechekule_checks_total{target="https://example.com",status="CONNECTION_FAILED"} 1`,
+		`chechekule_check_duration_seconds_bucket{target="api",le="0.05"} 1`,
+		`chechekule_check_duration_seconds_bucket{target="api",le="2.5"} 2`,
+		`chechekule_check_duration_seconds_bucket{target="api",le="+Inf"} 2`,
+		`chechekule_check_duration_seconds_sum{target="api"} 2.03`,
+		`chechekule_check_duration_seconds_count{target="api"} 2`,
+		`chechekule_last_success_timestamp_seconds{target="api"} 1.7e+09`,
+		`chechekule_up{target="api"} 0`,
+		`chechekule_up{target="https://example.com"} 0`,
+		`chechekule_assert_failures_total{target="api"} 1`,
+		`chechekule_assert_failures_total{target="https://example.com"} 0`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("metrics output does not contain %q\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `chechekule_last_success_timestamp_seconds{target="https://example.com"}`) {
+		t.Errorf("last success timestamp must be omitted for a target that never succeeded\n%s", got)
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	// 空いているポートを確保してから metrics.listen に渡します
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	metricsAddr := listener.Addr().String()
+	listener.Close()
+
+	config := &Config{
+		Name:     "local",
+		URL:      server.URL,
+		Interval: 50 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Metrics: MetricsConfig{Listen: metricsAddr},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scraped := make(chan string, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		resp, err := http.Get("http://" + metricsAddr + "/metrics")
+		if err != nil {
+			scraped <- err.Error()
+		} else {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			scraped <- string(body)
+		}
+		cancel()
+	}()
+
+	if err := runChecker(t, ctx, config); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	body := <-scraped
+	if !strings.Contains(body, `chechekule_up{target="local"} 1`) {
+		t.Errorf("Expected up gauge for target, got %s", body)
+	}
+	if !strings.Contains(body, `chechekule_checks_total{target="local",status="200"}`) {
+		t.Errorf("Expected checks counter for target, got %s", body)
+	}
+}
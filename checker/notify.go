@@ -0,0 +1,349 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// test-notify でサンプルを送信する際のイベント名
+const NotificationEventTest = "test"
+
+type NotificationConfig struct {
+	URL               string            `yaml:"url"`
+	Method            string            `yaml:"method"`
+	Headers           map[string]string `yaml:"headers"`
+	Template          string            `yaml:"template"`
+	Timeout           time.Duration     `yaml:"timeout"`
+	Retries           int               `yaml:"retries"`
+	Backoff           time.Duration     `yaml:"backoff"`
+	FailureThreshold  int               `yaml:"failure_threshold"`
+	RecoveryThreshold int               `yaml:"recovery_threshold"`
+}
+
+func (n NotificationConfig) validate() error {
+	if n.URL == "" {
+		return fmt.Errorf("notification url is required")
+	}
+	if n.Retries < 0 {
+		return fmt.Errorf("notification retries must not be negative")
+	}
+	if _, err := n.parseTemplate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (n NotificationConfig) timeout() time.Duration {
+	if n.Timeout == 0 {
+		return 10 * time.Second
+	}
+	return n.Timeout
+}
+
+func (n NotificationConfig) backoff() time.Duration {
+	if n.Backoff == 0 {
+		return time.Second
+	}
+	return n.Backoff
+}
+
+// redactedURL はトークンを含むことが多い Webhook の URL を、出力してよいスキームとホストだけにします
+func (n NotificationConfig) redactedURL() string {
+	u, err := url.Parse(n.URL)
+	if err != nil || u.Host == "" {
+		return "(invalid url)"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func (n NotificationConfig) parseTemplate() (*template.Template, error) {
+	if n.Template == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("notification").Funcs(templateFuncs).Option("missingkey=error").Parse(n.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notification template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// render は通知の本文を作成します。テンプレートが未指定の場合はフックと同じ JSON を送ります
+func (n NotificationConfig) render(target *Config, payload hookPayload, result *Result) ([]byte, error) {
+	tmpl, err := n.parseTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if tmpl == nil {
+		return json.Marshal(payload)
+	}
+
+	data := target.templateData(result)
+	data["target"] = target.targetName()
+	data["event"] = payload.Event
+	data["outageStart"] = payload.OutageStart
+	data["consecutiveFailures"] = payload.ConsecutiveFailures
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute notification template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// send は 2xx が返るまでバックオフを倍にしながら retries 回まで再送します。ctx がキャンセルされた場合は再送を待たずに中断します
+func (n NotificationConfig) send(ctx context.Context, body []byte) error {
+	client := &http.Client{Timeout: n.timeout()}
+	method := n.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	attempts := n.Retries + 1
+	backoff := n.backoff()
+	var err error
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("gave up sending notification after %d attempts: %w", i, ctx.Err())
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		if err = n.sendOnce(ctx, client, method, body); err == nil {
+			return nil
+		}
+	}
+	// net/http のエラーには URL がそのまま含まれるため、伏せてから返します
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = n.redactedURL()
+	}
+	return fmt.Errorf("failed to send notification after %d attempts: %w", attempts, err)
+}
+
+func (n NotificationConfig) sendOnce(ctx context.Context, client *http.Client, method string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(method), n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range n.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// notifyJob は送信を待っている通知です
+type notifyJob struct {
+	notification NotificationConfig
+	payload      hookPayload
+	result       *Result
+}
+
+// notifyQueue はターゲットの通知を状態が変化した順に1つずつ送るためのキューです。
+// 再送中の障害の通知が、その後の復旧の通知より後に届かないようにします
+type notifyQueue struct {
+	jobs    []notifyJob
+	running bool
+}
+
+// notifier はターゲットの状態が変化したときに Webhook へ通知します。fan_out のターゲットは IP ごとに判定します
+type notifier struct {
+	mu       sync.Mutex
+	targets  []*Config
+	trackers map[targetKey][]*stateTracker
+	queues   map[*Config]*notifyQueue
+	wg       sync.WaitGroup
+	logf     func(target *Config, format string, args ...interface{})
+
+	// ctx は stop の期限を過ぎたときに送信中の通知と再送を止めるために使います
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newNotificationTrackers(target *Config) []*stateTracker {
+	trackers := make([]*stateTracker, len(target.Notifications))
+	for i, notification := range target.Notifications {
+		trackers[i] = newStateTracker(notification.FailureThreshold, notification.RecoveryThreshold)
+	}
+	return trackers
+}
+
+func newNotifier(targets []*Config, logf func(target *Config, format string, args ...interface{})) *notifier {
+	n := &notifier{
+		targets:  targets,
+		trackers: make(map[targetKey][]*stateTracker),
+		queues:   make(map[*Config]*notifyQueue),
+		logf:     logf,
+	}
+	n.ctx, n.cancel = context.WithCancel(context.Background())
+	for _, target := range targets {
+		n.trackers[targetKey{target: target}] = newNotificationTrackers(target)
+		n.queues[target] = &notifyQueue{}
+	}
+	return n
+}
+
+func (n *notifier) Write(target *Config, result *Result) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if _, ok := n.trackers[targetKey{target: target}]; !ok {
+		return
+	}
+	key := targetKey{target: target, ip: result.IP}
+	trackers, ok := n.trackers[key]
+	if !ok {
+		trackers = newNotificationTrackers(target)
+		n.trackers[key] = trackers
+	}
+
+	for i, tracker := range trackers {
+		var event string
+		switch tracker.update(result) {
+		case transitionDown:
+			event = HookEventFailure
+		case transitionUp:
+			event = HookEventRecovery
+		default:
+			continue
+		}
+
+		queue := n.queues[target]
+		queue.jobs = append(queue.jobs, notifyJob{
+			notification: target.Notifications[i],
+			payload:      newHookPayload(target, event, tracker, result),
+			result:       result,
+		})
+		if !queue.running {
+			queue.running = true
+			n.wg.Add(1)
+			go n.drain(target, queue)
+		}
+	}
+}
+
+// drain はキューが空になるまで通知を順に送ります。stop の期限を過ぎた場合は残りを破棄します
+func (n *notifier) drain(target *Config, queue *notifyQueue) {
+	defer n.wg.Done()
+	for {
+		n.mu.Lock()
+		if len(queue.jobs) == 0 || n.ctx.Err() != nil {
+			queue.jobs = nil
+			queue.running = false
+			n.mu.Unlock()
+			return
+		}
+		job := queue.jobs[0]
+		queue.jobs = queue.jobs[1:]
+		n.mu.Unlock()
+
+		if err := notify(n.ctx, target, job.notification, job.payload, job.result); err != nil {
+			n.logf(target, "Failed to send %s notification: %v\n", job.payload.Event, err)
+		}
+	}
+}
+
+// stop は送信を待っている通知がすべて終わるまで待ちます。
+// 送信中と次の通知の分として最も長い timeout の2倍を過ぎても終わらない場合は、送信と再送を止めて残りを破棄します
+func (n *notifier) stop() {
+	defer n.cancel()
+	timeout := n.stopTimeout()
+	if timeout == 0 {
+		// 通知が設定されていなければ待つものはありません
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+	case <-timer.C:
+		n.logf(nil, "Notifications did not finish within %v, dropping the rest\n", timeout)
+		n.cancel()
+		<-done
+	}
+}
+
+// stopTimeout は stop で待つ時間です。通知が設定されていない場合は 0 です
+func (n *notifier) stopTimeout() time.Duration {
+	var timeout time.Duration
+	for _, target := range n.targets {
+		for _, notification := range target.Notifications {
+			timeout = max(timeout, notification.timeout())
+		}
+	}
+	return 2 * timeout
+}
+
+func notify(ctx context.Context, target *Config, notification NotificationConfig, payload hookPayload, result *Result) error {
+	body, err := notification.render(target, payload, result)
+	if err != nil {
+		return err
+	}
+	return notification.send(ctx, body)
+}
+
+// SendTestNotifications は設定された全ての通知先にサンプルの障害を送信し、通知先ごとの結果を out と errOut に書き出します
+func SendTestNotifications(config *Config, out, errOut io.Writer) error {
+	var failed []string
+	sent := 0
+	for _, target := range config.CheckTargets() {
+		for _, notification := range target.Notifications {
+			result := &Result{
+				RequestedAt: time.Now(),
+				StatusCode:  StatusConnectionFailed,
+				Duration:    123 * time.Millisecond,
+				URL:         target.URL,
+				Err:         fmt.Errorf("this is a test notification from chechekule"),
+			}
+			tracker := newStateTracker(1, 1)
+			tracker.update(result)
+			payload := newHookPayload(target, NotificationEventTest, tracker, result)
+
+			if err := notify(context.Background(), target, notification, payload, result); err != nil {
+				fmt.Fprintf(errOut, "%s -> %s: %v\n", target.targetName(), notification.redactedURL(), err)
+				failed = append(failed, notification.redactedURL())
+				continue
+			}
+			fmt.Fprintf(out, "%s -> %s: sent\n", target.targetName(), notification.redactedURL())
+			sent++
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to send %d notification(s)", len(failed))
+	}
+	if sent == 0 {
+		return fmt.Errorf("no notifications are configured")
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 出力形式
+const (
+	OutputFormatText  = "text"
+	OutputFormatJSONL = "jsonl"
+)
+
+// jsonTimings は JSON Lines 出力用のフェーズごとの所要時間(ミリ秒)です
+type jsonTimings struct {
+	DNSMs      float64 `json:"dns_ms"`
+	ConnectMs  float64 `json:"connect_ms"`
+	TLSMs      float64 `json:"tls_ms"`
+	TTFBMs     float64 `json:"ttfb_ms"`
+	TransferMs float64 `json:"transfer_ms"`
+}
+
+// jsonRecord は JSON Lines 出力の1行分です
+type jsonRecord struct {
+	Timestamp     string      `json:"timestamp"`
+	Target        string      `json:"target,omitempty"`
+	IP            string      `json:"ip,omitempty"`
+	StatusCode    int         `json:"status_code"`
+	Error         string      `json:"error,omitempty"`
+	ErrorMessage  string      `json:"error_message,omitempty"`
+	DurationMs    float64     `json:"duration_ms"`
+	Size          int         `json:"size"`
+	AssertFailure string      `json:"assert_failure,omitempty"`
+	URL           string      `json:"url"`
+	RemoteIP      string      `json:"remote_ip,omitempty"`
+	Reused        bool        `json:"reused"`
+	Proto         string      `json:"proto,omitempty"`
+	Timings       jsonTimings `json:"timings"`
+	Steps         []jsonStep  `json:"steps,omitempty"`
+	FailedStep    string      `json:"failed_step,omitempty"`
+	TLS           *jsonTLS    `json:"tls,omitempty"`
+}
+
+// jsonTLS は TLS 接続とサーバー証明書の情報です
+type jsonTLS struct {
+	Version       string   `json:"version"`
+	Cipher        string   `json:"cipher"`
+	Issuer        string   `json:"issuer,omitempty"`
+	SANs          []string `json:"sans,omitempty"`
+	NotAfter      string   `json:"not_after,omitempty"`
+	DaysRemaining *int     `json:"days_remaining,omitempty"`
+}
+
+// jsonStep はシナリオの各ステップの結果です
+type jsonStep struct {
+	Name       string  `json:"name"`
+	StatusCode int     `json:"status_code"`
+	Error      string  `json:"error,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+func milliseconds(d time.Duration) float64 {
+	return d.Seconds() * 1000
+}
+
+func (c *Config) newJSONRecord(result *Result) jsonRecord {
+	record := jsonRecord{
+		Timestamp:  result.RequestedAt.Format("2006-01-02T15:04:05.000Z07:00"),
+		Target:     c.targetName(),
+		IP:         result.IP,
+		StatusCode: result.StatusCode,
+		DurationMs: milliseconds(result.Duration),
+		Size:       len(result.Body),
+		URL:        result.URL,
+		RemoteIP:   result.RemoteIP,
+		Reused:     result.Reused,
+		Proto:      result.Proto,
+		Timings: jsonTimings{
+			DNSMs:      milliseconds(result.Timings.DNS),
+			ConnectMs:  milliseconds(result.Timings.Connect),
+			TLSMs:      milliseconds(result.Timings.TLS),
+			TTFBMs:     milliseconds(result.Timings.TTFB),
+			TransferMs: milliseconds(result.Timings.Transfer),
+		},
+		FailedStep: result.FailedStep,
+	}
+	for _, step := range result.Steps {
+		record.Steps = append(record.Steps, jsonStep{
+			Name:       step.Name,
+			StatusCode: step.StatusCode,
+			Error:      errorMessages[step.StatusCode],
+			DurationMs: milliseconds(step.Duration),
+		})
+	}
+	if result.TLS != nil {
+		record.TLS = &jsonTLS{
+			Version: result.TLS.Version,
+			Cipher:  result.TLS.Cipher,
+			Issuer:  result.TLS.Issuer,
+			SANs:    result.TLS.SANs,
+		}
+		if !result.TLS.NotAfter.IsZero() {
+			record.TLS.NotAfter = result.TLS.NotAfter.Format("2006-01-02T15:04:05Z07:00")
+			days := result.TLS.DaysRemaining
+			record.TLS.DaysRemaining = &days
+		}
+	}
+	if result.StatusCode < 0 {
+		record.Error = errorMessages[result.StatusCode]
+	}
+	if result.Err != nil {
+		record.ErrorMessage = result.Err.Error()
+	}
+	if result.AssertErr != nil {
+		record.AssertFailure = result.AssertErr.Error()
+	}
+	return record
+}
+
+// formatJSONL は結果を改行を含まない JSON 1行に変換します
+func (c *Config) formatJSONL(result *Result) (string, error) {
+	data, err := json.Marshal(c.newJSONRecord(result))
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,160 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormatJSONL(t *testing.T) {
+	config := &Config{
+		Name:   "api",
+		Output: OutputConfig{Format: OutputFormatJSONL},
+	}
+	requestedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		result *Result
+		want   map[string]interface{}
+	}{
+		{
+			name: "success",
+			result: &Result{
+				RequestedAt: requestedAt,
+				StatusCode:  200,
+				Duration:    1500 * time.Microsecond,
+				Body:        []byte("hello"),
+				URL:         "https://example.com/final",
+			},
+			want: map[string]interface{}{
+				"timestamp":   "2024-01-02T03:04:05.000Z",
+				"target":      "api",
+				"status_code": float64(200),
+				"duration_ms": 1.5,
+				"size":        float64(5),
+				"url":         "https://example.com/final",
+			},
+		},
+		{
+			name: "error",
+			result: &Result{
+				RequestedAt: requestedAt,
+				StatusCode:  StatusConnectionFailed,
+				Err:         errors.New("connection refused"),
+				URL:         "https://example.com",
+			},
+			want: map[string]interface{}{
+				"status_code":   float64(StatusConnectionFailed),
+				"error":         "CONNECTION_FAILED",
+				"error_message": "connection refused",
+			},
+		},
+		{
+			name: "assert failed",
+			result: &Result{
+				RequestedAt: requestedAt,
+				StatusCode:  StatusAssertFailed,
+				AssertErr:   errors.New("body does not match regex ok"),
+			},
+			want: map[string]interface{}{
+				"error":          "ASSERT_FAILED",
+				"assert_failure": "body does not match regex ok",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := config.formatJSONL(tt.result)
+			if err != nil {
+				t.Fatalf("formatJSONL() error = %v", err)
+			}
+			if strings.Contains(line, "\n") {
+				t.Errorf("formatJSONL() must be a single line: %q", line)
+			}
+
+			var got map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &got); err != nil {
+				t.Fatalf("Failed to parse %s: %v", line, err)
+			}
+			for k, v := range tt.want {
+				if got[k] != v {
+					t.Errorf("%s = %v, want %v", k, got[k], v)
+				}
+			}
+			if _, ok := got["timings"].(map[string]interface{}); !ok {
+				t.Errorf("timings = %v, want object", got["timings"])
+			}
+		})
+	}
+}
+
+func TestFormatJSONLUnnamedTarget(t *testing.T) {
+	// 名前の無い単一ターゲットでもフックの payload と同じく URL をターゲットとして出力すること
+	config := &Config{
+		URL:    "https://example.com/health",
+		Output: OutputConfig{Format: OutputFormatJSONL},
+	}
+	line, err := config.formatJSONL(&Result{StatusCode: 200})
+	if err != nil {
+		t.Fatalf("formatJSONL() error = %v", err)
+	}
+	if !strings.Contains(line, `"target":"https://example.com/health"`) {
+		t.Errorf("formatJSONL() = %s, want target", line)
+	}
+}
+
+func TestJSONLLog(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "test.jsonl")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL:      server.URL,
+		Interval: 100 * time.Millisecond,
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+		Log: &LogConfig{
+			Path: logPath,
+		},
+		Output: OutputConfig{Format: OutputFormatJSONL},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 250*time.Millisecond)
+	defer cancel()
+
+	if err := runChecker(t, ctx, config, NewLogSink(os.Stderr)); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	content, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) == 0 {
+		t.Fatalf("Expected log lines")
+	}
+	for _, line := range lines {
+		var record jsonRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to parse %s: %v", line, err)
+		}
+		if record.StatusCode != 200 || record.URL != server.URL {
+			t.Errorf("record = %+v, want status 200 for %s", record, server.URL)
+		}
+	}
+}
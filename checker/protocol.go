@@ -0,0 +1,89 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// protocol・asserts.protocol に指定できる値
+const (
+	ProtocolAuto  = "auto"
+	ProtocolHTTP1 = "http1"
+	ProtocolHTTP2 = "http2"
+	ProtocolH2C   = "h2c"
+)
+
+// validateProtocol は protocol と URL のスキームの組み合わせを検証します。
+// http2 は TLS の ALPN でネゴシエートするため https、h2c は平文のため http でのみ使えます
+func (c *Config) validateProtocol() error {
+	var scheme string
+	switch c.Protocol {
+	case "", ProtocolAuto, ProtocolHTTP1:
+		return nil
+	case ProtocolHTTP2:
+		scheme = "https"
+	case ProtocolH2C:
+		scheme = "http"
+	default:
+		return fmt.Errorf("unknown protocol: %s", c.Protocol)
+	}
+
+	urls := []string{c.URL}
+	for _, step := range c.Steps {
+		urls = append(urls, step.URL)
+	}
+	for _, u := range urls {
+		if u != "" && !strings.HasPrefix(strings.ToLower(u), scheme+"://") {
+			return fmt.Errorf("protocol %s requires an %s URL: %s", c.Protocol, scheme, u)
+		}
+	}
+	return nil
+}
+
+// protocols は Transport.Protocols に設定する値を返します。
+// auto ではカスタムの DialContext・TLS 設定を使っていても HTTP/2 をネゴシエートします
+func (c *Config) protocols() *http.Protocols {
+	protocols := &http.Protocols{}
+	switch c.Protocol {
+	case ProtocolHTTP1:
+		protocols.SetHTTP1(true)
+	case ProtocolHTTP2:
+		protocols.SetHTTP2(true)
+	case ProtocolH2C:
+		protocols.SetUnencryptedHTTP2(true)
+	default:
+		protocols.SetHTTP1(true)
+		protocols.SetHTTP2(true)
+	}
+	return protocols
+}
+
+func validateProtocolAssert(expected string) error {
+	switch expected {
+	case "", ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C:
+		return nil
+	default:
+		return fmt.Errorf("asserts.protocol must be http1, http2 or h2c: %s", expected)
+	}
+}
+
+// checkProtocol はネゴシエートされたプロトコルを検証します。
+// http2 は TLS・平文のどちらの HTTP/2 でも成功し、h2c は平文の HTTP/2 のみ成功します
+func checkProtocol(expected string, resp *http.Response) error {
+	var ok bool
+	switch expected {
+	case "":
+		return nil
+	case ProtocolHTTP1:
+		ok = resp.ProtoMajor == 1
+	case ProtocolHTTP2:
+		ok = resp.ProtoMajor == 2
+	case ProtocolH2C:
+		ok = resp.ProtoMajor == 2 && resp.TLS == nil
+	}
+	if !ok {
+		return fmt.Errorf("protocol: expected %s, got %s", expected, resp.Proto)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProtocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	h2cServer := httptest.NewUnstartedServer(handler)
+	h2cServer.Config.Protocols = &http.Protocols{}
+	h2cServer.Config.Protocols.SetHTTP1(true)
+	h2cServer.Config.Protocols.SetUnencryptedHTTP2(true)
+	h2cServer.Start()
+	defer h2cServer.Close()
+
+	tests := []struct {
+		name      string
+		url       string
+		protocol  string
+		keepAlive bool
+		want      string
+	}{
+		{name: "auto over TLS", url: tlsServer.URL, protocol: "", want: "HTTP/2.0"},
+		{name: "http1 over TLS", url: tlsServer.URL, protocol: ProtocolHTTP1, want: "HTTP/1.1"},
+		{name: "http2 over TLS", url: tlsServer.URL, protocol: ProtocolHTTP2, want: "HTTP/2.0"},
+		{name: "http2 with keep_alive", url: tlsServer.URL, protocol: ProtocolHTTP2, keepAlive: true, want: "HTTP/2.0"},
+		{name: "auto over cleartext", url: h2cServer.URL, protocol: ProtocolAuto, want: "HTTP/1.1"},
+		{name: "h2c", url: h2cServer.URL, protocol: ProtocolH2C, want: "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				URL:        tt.url,
+				Protocol:   tt.protocol,
+				Connection: ConnectionConfig{KeepAlive: tt.keepAlive},
+				Interval:   time.Second,
+				Timeout:    TimeoutConfig{Connect: time.Second, Read: time.Second},
+			}
+			if err := config.Validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			client := newTLSTestClient(t, config, tlsServer)
+			for i := 0; i < 2; i++ {
+				result, err := checkOnce(context.Background(), config, client)
+				if err != nil {
+					t.Fatalf("checkOnce() error = %v", err)
+				}
+				if result.Err != nil {
+					t.Fatalf("check failed: %v", result.Err)
+				}
+				if result.Proto != tt.want || string(result.Body) != tt.want {
+					t.Errorf("proto = %q, server saw %q, want %q", result.Proto, result.Body, tt.want)
+				}
+				if wantReused := tt.keepAlive && i > 0; result.Reused != wantReused {
+					t.Errorf("check %d: reused = %v, want %v", i, result.Reused, wantReused)
+				}
+			}
+		})
+	}
+}
+
+func TestProtocolAssert(t *testing.T) {
+	tlsResp := &http.Response{ProtoMajor: 2, Proto: "HTTP/2.0", TLS: &tls.ConnectionState{}}
+	h2cResp := &http.Response{ProtoMajor: 2, Proto: "HTTP/2.0"}
+	http1Resp := &http.Response{ProtoMajor: 1, Proto: "HTTP/1.1"}
+
+	tests := []struct {
+		expected string
+		resp     *http.Response
+		wantErr  string
+	}{
+		{expected: "", resp: http1Resp},
+		{expected: ProtocolHTTP1, resp: http1Resp},
+		{expected: ProtocolHTTP1, resp: tlsResp, wantErr: "protocol: expected http1, got HTTP/2.0"},
+		{expected: ProtocolHTTP2, resp: tlsResp},
+		{expected: ProtocolHTTP2, resp: h2cResp},
+		{expected: ProtocolHTTP2, resp: http1Resp, wantErr: "protocol: expected http2, got HTTP/1.1"},
+		{expected: ProtocolH2C, resp: h2cResp},
+		{expected: ProtocolH2C, resp: tlsResp, wantErr: "protocol: expected h2c, got HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		err := checkProtocol(tt.expected, tt.resp)
+		if tt.wantErr == "" {
+			if err != nil {
+				t.Errorf("checkProtocol(%q, %s) error = %v", tt.expected, tt.resp.Proto, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.wantErr {
+			t.Errorf("checkProtocol(%q, %s) error = %v, want %q", tt.expected, tt.resp.Proto, err, tt.wantErr)
+		}
+	}
+}
+
+func TestProtocolValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "unknown protocol", config: Config{URL: "http://example.com", Protocol: "spdy"}, wantErr: "unknown protocol"},
+		{name: "http2 over http", config: Config{URL: "http://example.com", Protocol: ProtocolHTTP2}, wantErr: "requires an https URL"},
+		{name: "h2c over https", config: Config{URL: "https://example.com", Protocol: ProtocolH2C}, wantErr: "requires an http URL"},
+		{name: "h2c step over https", config: Config{Protocol: ProtocolH2C, Steps: []StepConfig{{URL: "https://example.com"}}}, wantErr: "requires an http URL"},
+		{name: "unknown assert", config: Config{URL: "http://example.com", Asserts: AssertsConfig{Protocol: "HTTP/2.0"}}, wantErr: "asserts.protocol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Interval = time.Second
+			err := tt.config.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
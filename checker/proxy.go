@@ -0,0 +1,71 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// ProxyConfig はプロキシの設定です。url も disabled も指定しない場合は HTTP_PROXY などの環境変数に従います。
+// disabled はトップレベルの url を引き継いだターゲットでも優先されます
+type ProxyConfig struct {
+	URL      string `yaml:"url"`
+	Disabled bool   `yaml:"disabled"`
+}
+
+func (p *ProxyConfig) validate() error {
+	if p.URL == "" {
+		return nil
+	}
+	proxyURL, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("invalid proxy.url: %w", err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("proxy.url must be an http, https or socks5 URL: %s", p.URL)
+	}
+	if proxyURL.Host == "" {
+		return fmt.Errorf("proxy.url has no host: %s", p.URL)
+	}
+	return nil
+}
+
+// proxyFunc は Transport.Proxy に設定する関数を返します。
+// 認証情報は URL に含めれば http(s) では Proxy-Authorization、socks5 ではユーザー名・パスワード認証に使われます
+func (p *ProxyConfig) proxyFunc() (func(*http.Request) (*url.URL, error), error) {
+	switch {
+	case p.Disabled:
+		return nil, nil
+	case p.URL != "":
+		proxyURL, err := url.Parse(p.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy.url: %w", err)
+		}
+		return http.ProxyURL(proxyURL), nil
+	default:
+		return http.ProxyFromEnvironment, nil
+	}
+}
+
+// forwardingProxy は req を転送した HTTP プロキシの URL を返します。
+// CONNECT や SOCKS5 でトンネルした場合とプロキシを使わない場合は、レスポンスはターゲットからのものなので nil を返します
+func (p *ProxyConfig) forwardingProxy(req *http.Request) *url.URL {
+	if req.URL.Scheme != "http" {
+		return nil
+	}
+	proxy, err := p.proxyFunc()
+	if err != nil || proxy == nil {
+		return nil
+	}
+	proxyURL, err := proxy(req)
+	if err != nil || proxyURL == nil {
+		return nil
+	}
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return proxyURL
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package checker
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// OutputSink は結果を1行ずつ書き出します。output.format が jsonl であれば JSON Lines、それ以外はタブ区切りのテキストです。
+// アサートに失敗した場合は、理由とレスポンスのヘッダー・ボディを errOut に書き出します
+type OutputSink struct {
+	mu     sync.Mutex
+	out    io.Writer
+	errOut io.Writer
+}
+
+// NewOutputSink は結果を out に、エラーを errOut に書き出す OutputSink を作成します
+func NewOutputSink(out, errOut io.Writer) *OutputSink {
+	return &OutputSink{out: out, errOut: errOut}
+}
+
+func (s *OutputSink) Write(target *Config, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if line, err := target.formatResult(result); err != nil {
+		target.errorf(s.errOut, "%v\n", err)
+	} else {
+		fmt.Fprintln(s.out, line)
+	}
+
+	if result.AssertErr != nil {
+		target.errorf(s.errOut, "Assert failed: %v\n", result.AssertErr)
+		target.errorf(s.errOut, "Response Headers:\n")
+		for k, v := range result.Response.Header {
+			target.errorf(s.errOut, "  %s: %v\n", k, v)
+		}
+		target.errorf(s.errOut, "Response Body:\n%s\n", string(result.Body))
+	}
+}
+
+// LogSink は log.path のファイルに結果を追記します。log が設定されていないターゲットの結果は無視します
+type LogSink struct {
+	errOut io.Writer
+}
+
+// NewLogSink はログの書き込みに失敗した場合に errOut に書き出す LogSink を作成します
+func NewLogSink(errOut io.Writer) *LogSink {
+	return &LogSink{errOut: errOut}
+}
+
+func (s *LogSink) Write(target *Config, result *Result) {
+	if target.Log == nil {
+		return
+	}
+	if err := target.WriteLog(result); err != nil {
+		target.errorf(s.errOut, "Failed to write log: %v\n", err)
+	}
+}
+
+// formatResult は結果を1行にします。テキスト形式ではターゲット名がある場合は先頭に付与します
+func (c *Config) formatResult(result *Result) (string, error) {
+	if c.Output.Format == OutputFormatJSONL {
+		return c.formatJSONL(result)
+	}
+
+	fields := []string{result.RequestedAt.Format("2006-01-02T15:04:05.000Z07:00")}
+	if c.Name != "" {
+		fields = append(fields, c.Name)
+	}
+	fields = append(fields, result.StatusText(), result.Duration.String())
+	if c.Output.Timings {
+		fields = append(fields,
+			"dns="+result.Timings.DNS.String(),
+			"connect="+result.Timings.Connect.String(),
+			"tls="+result.Timings.TLS.String(),
+			"ttfb="+result.Timings.TTFB.String(),
+			"transfer="+result.Timings.Transfer.String(),
+			"reused="+strconv.FormatBool(result.Reused),
+		)
+	}
+	if result.IP != "" {
+		fields = append(fields, "ip="+result.IP)
+	}
+	if len(result.Steps) > 0 {
+		fields = append(fields, "steps="+formatSteps(result.Steps))
+	}
+	if result.FailedStep != "" {
+		fields = append(fields, "failed_step="+result.FailedStep)
+	}
+	return strings.Join(fields, "\t"), nil
+}
+
+// errorf はターゲット名を付けて w に書き出します
+func (c *Config) errorf(w io.Writer, format string, args ...interface{}) {
+	if c.Name != "" {
+		format = "[" + c.Name + "] " + format
+	}
+	fmt.Fprintf(w, format, args...)
+}
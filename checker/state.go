@@ -0,0 +1,61 @@
+package checker
+
+import "time"
+
+// stateTransition は連続した結果からターゲットの状態が変化したことを表します
+type stateTransition int
+
+const (
+	transitionNone stateTransition = iota
+	transitionDown
+	transitionUp
+)
+
+// stateTracker は連続失敗・連続成功の回数からターゲットの up/down を判定します。
+// 開始直後は up とみなし、最初の成功では遷移を通知しません
+type stateTracker struct {
+	failureThreshold     int
+	recoveryThreshold    int
+	down                 bool
+	consecutiveFailures  int
+	consecutiveSuccesses int
+	failureStart         time.Time
+	outageStart          time.Time
+}
+
+func newStateTracker(failureThreshold, recoveryThreshold int) *stateTracker {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if recoveryThreshold < 1 {
+		recoveryThreshold = 1
+	}
+	return &stateTracker{
+		failureThreshold:  failureThreshold,
+		recoveryThreshold: recoveryThreshold,
+	}
+}
+
+func (t *stateTracker) update(result *Result) stateTransition {
+	if result.Success() {
+		t.consecutiveFailures = 0
+		t.consecutiveSuccesses++
+		if t.down && t.consecutiveSuccesses >= t.recoveryThreshold {
+			t.down = false
+			return transitionUp
+		}
+		return transitionNone
+	}
+
+	t.consecutiveSuccesses = 0
+	t.consecutiveFailures++
+	if t.consecutiveFailures == 1 {
+		t.failureStart = result.RequestedAt
+	}
+	if !t.down && t.consecutiveFailures >= t.failureThreshold {
+		t.down = true
+		t.outageStart = t.failureStart
+		return transitionDown
+	}
+	return transitionNone
+}
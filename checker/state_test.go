@@ -0,0 +1,51 @@
+package checker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStateTracker(t *testing.T) {
+	base := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	tracker := newStateTracker(2, 2)
+
+	steps := []struct {
+		success bool
+		want    stateTransition
+	}{
+		{success: true, want: transitionNone},
+		{success: false, want: transitionNone},
+		{success: true, want: transitionNone},
+		{success: false, want: transitionNone},
+		{success: false, want: transitionDown},
+		{success: false, want: transitionNone},
+		{success: true, want: transitionNone},
+		{success: false, want: transitionNone},
+		{success: true, want: transitionNone},
+		{success: true, want: transitionUp},
+		{success: true, want: transitionNone},
+	}
+
+	for i, step := range steps {
+		result := &Result{RequestedAt: base.Add(time.Duration(i) * time.Second), StatusCode: 200}
+		if !step.success {
+			result.StatusCode = StatusConnectionFailed
+		}
+		if got := tracker.update(result); got != step.want {
+			t.Errorf("step %d: update() = %v, want %v", i, got, step.want)
+		}
+		if i == 4 && !tracker.outageStart.Equal(base.Add(3*time.Second)) {
+			t.Errorf("outageStart = %v, want the first failure of the streak", tracker.outageStart)
+		}
+	}
+}
+
+func TestStateTrackerDefaultThresholds(t *testing.T) {
+	tracker := newStateTracker(0, 0)
+	if got := tracker.update(&Result{StatusCode: StatusTimeout}); got != transitionDown {
+		t.Errorf("update() = %v, want transitionDown", got)
+	}
+	if got := tracker.update(&Result{StatusCode: 200}); got != transitionUp {
+		t.Errorf("update() = %v, want transitionUp", got)
+	}
+}
@@ -0,0 +1,279 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// extract に指定できる抽出方法
+const (
+	ExtractRegex  = "regex"
+	ExtractJSON   = "json"
+	ExtractHeader = "header"
+	ExtractCookie = "cookie"
+)
+
+// ExtractConfig はステップのレスポンスから値を取り出して変数に保存する設定です
+type ExtractConfig struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"`
+	Expr string `yaml:"expr"`
+}
+
+// StepConfig はシナリオの1リクエスト分の設定です
+type StepConfig struct {
+	Name    string            `yaml:"name"`
+	Method  string            `yaml:"method"`
+	URL     string            `yaml:"url"`
+	Headers map[string]string `yaml:"headers"`
+	Body    string            `yaml:"body"`
+	Asserts AssertsConfig     `yaml:"asserts"`
+	Extract []ExtractConfig   `yaml:"extract"`
+}
+
+// UnmarshalYAML はトップレベルと同じく、ステップの asserts を 200 のみ許可する設定で初期化します
+func (s *StepConfig) UnmarshalYAML(node *yaml.Node) error {
+	type plain StepConfig
+	step := plain{
+		Asserts: AssertsConfig{
+			StatusCode: StatusCodeAssert{
+				Values: []int{200},
+			},
+		},
+	}
+	if err := node.Decode(&step); err != nil {
+		return err
+	}
+	*s = StepConfig(step)
+	return nil
+}
+
+// stepName はステップの表示名です。name が無い場合は "#1" のような連番になります
+func (s *StepConfig) stepName(index int) string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return fmt.Sprintf("#%d", index+1)
+}
+
+// validate はステップを検証します。vars はそれより前のステップの extract で定義される変数で、
+// テンプレートの構文と未定義の変数を毎回のチェックで失敗する前に検出します
+func (s *StepConfig) validate(vars map[string]string) error {
+	if s.URL == "" {
+		return fmt.Errorf("url is required")
+	}
+	if _, err := renderStepTemplate(s.URL, vars); err != nil {
+		return fmt.Errorf("url: %w", err)
+	}
+	if _, err := renderStepTemplate(s.Body, vars); err != nil {
+		return fmt.Errorf("body: %w", err)
+	}
+	for k, v := range s.Headers {
+		if _, err := renderStepTemplate(v, vars); err != nil {
+			return fmt.Errorf("headers[%s]: %w", k, err)
+		}
+	}
+	if err := s.Asserts.validate(); err != nil {
+		return err
+	}
+	for i, extract := range s.Extract {
+		if extract.Name == "" || extract.Expr == "" {
+			return fmt.Errorf("extract[%d]: name and expr are required", i)
+		}
+		switch extract.Type {
+		case ExtractRegex:
+			if _, err := regexp.Compile(extract.Expr); err != nil {
+				return fmt.Errorf("extract[%d]: invalid regex: %w", i, err)
+			}
+		case ExtractJSON:
+			if _, err := parseJSONPath(extract.Expr); err != nil {
+				return fmt.Errorf("extract[%d]: %w", i, err)
+			}
+		case ExtractHeader, ExtractCookie:
+		default:
+			return fmt.Errorf("extract[%d]: unknown type: %s", i, extract.Type)
+		}
+	}
+	return nil
+}
+
+// StepResult はシナリオの各ステップの結果です
+type StepResult struct {
+	Name       string
+	StatusCode int
+	Duration   time.Duration
+}
+
+// formatSteps はステップごとの結果を "login:200:12ms,api:ASSERT_FAILED:3ms" の形式にします
+func formatSteps(steps []StepResult) string {
+	fields := make([]string, 0, len(steps))
+	for _, step := range steps {
+		status := (&Result{StatusCode: step.StatusCode}).StatusText()
+		fields = append(fields, step.Name+":"+status+":"+step.Duration.String())
+	}
+	return strings.Join(fields, ",")
+}
+
+// renderStepTemplate は前のステップで抽出した変数をテンプレートに埋め込みます。未定義の変数はエラーです
+func renderStepTemplate(text string, vars map[string]string) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+	tmpl, err := template.New("step").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("failed to execute template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// stepConfig はステップ用の設定を作成します。タイムアウトやリダイレクトはターゲットの設定を引き継ぎ、
+// ヘッダーはターゲットのものにステップのものを重ねます
+func (c *Config) stepConfig(step *StepConfig, vars map[string]string) (*Config, error) {
+	cp := c.clone()
+	cp.Steps = nil
+	cp.Method = step.Method
+	cp.BodyFile = ""
+	cp.Asserts = step.Asserts
+
+	var err error
+	if cp.URL, err = renderStepTemplate(step.URL, vars); err != nil {
+		return nil, fmt.Errorf("url: %w", err)
+	}
+	if cp.Body, err = renderStepTemplate(step.Body, vars); err != nil {
+		return nil, fmt.Errorf("body: %w", err)
+	}
+	if cp.Headers == nil && len(step.Headers) > 0 {
+		cp.Headers = make(map[string]string, len(step.Headers))
+	}
+	for k, v := range step.Headers {
+		if cp.Headers[k], err = renderStepTemplate(v, vars); err != nil {
+			return nil, fmt.Errorf("headers[%s]: %w", k, err)
+		}
+	}
+	return cp, nil
+}
+
+// checkScenario はステップを順に実行します。いずれかのステップが失敗した時点で打ち切り、そのステップの結果を全体の結果とします。
+// クッキーはクライアントの jar を通してステップ間で共有されます
+func checkScenario(ctx context.Context, config *Config, client *http.Client) (*Result, error) {
+	result := &Result{
+		Target:      config.targetName(),
+		RequestedAt: time.Now(),
+	}
+	vars := make(map[string]string)
+
+	for i := range config.Steps {
+		step := &config.Steps[i]
+		name := step.stepName(i)
+
+		stepConfig, err := config.stepConfig(step, vars)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", name, err)
+		}
+		stepRes, err := checkRequest(ctx, stepConfig, client)
+		if err != nil {
+			return nil, fmt.Errorf("step %s: %w", name, err)
+		}
+
+		result.Duration += stepRes.Duration
+		result.StatusCode = stepRes.StatusCode
+		result.Timings = stepRes.Timings
+		result.URL = stepRes.URL
+		result.Err = stepRes.Err
+		result.AssertErr = stepRes.AssertErr
+		result.Response = stepRes.Response
+		result.Body = stepRes.Body
+		result.TLS = stepRes.TLS
+		result.RemoteIP = stepRes.RemoteIP
+		result.Reused = stepRes.Reused
+		result.Proto = stepRes.Proto
+
+		if stepRes.Success() {
+			if err := extractValues(step.Extract, client, stepRes, vars); err != nil {
+				result.AssertErr = err
+				result.StatusCode = StatusAssertFailed
+			}
+		}
+		result.Steps = append(result.Steps, StepResult{
+			Name:       name,
+			StatusCode: result.StatusCode,
+			Duration:   stepRes.Duration,
+		})
+		if !result.Success() {
+			result.FailedStep = name
+			return result, nil
+		}
+	}
+
+	return result, nil
+}
+
+// extractValues はレスポンスから値を取り出して vars に保存します。値が見つからない場合はエラーです
+func extractValues(extracts []ExtractConfig, client *http.Client, result *Result, vars map[string]string) error {
+	for _, extract := range extracts {
+		value, err := extractValue(extract, client, result)
+		if err != nil {
+			return fmt.Errorf("extract %s: %w", extract.Name, err)
+		}
+		vars[extract.Name] = value
+	}
+	return nil
+}
+
+func extractValue(extract ExtractConfig, client *http.Client, result *Result) (string, error) {
+	switch extract.Type {
+	case ExtractRegex:
+		re, err := regexp.Compile(extract.Expr)
+		if err != nil {
+			return "", fmt.Errorf("invalid regex: %w", err)
+		}
+		// キャプチャグループがあれば最初のグループ、無ければマッチ全体を使います
+		match := re.FindSubmatch(result.Body)
+		if match == nil {
+			return "", fmt.Errorf("regex %s did not match body", extract.Expr)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	case ExtractJSON:
+		data, err := decodeJSONBody(result.Body)
+		if err != nil {
+			return "", err
+		}
+		value, found, err := lookupJSONPath(data, extract.Expr)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("json path %s not found", extract.Expr)
+		}
+		return jsonValueString(value), nil
+	case ExtractHeader:
+		values := result.Response.Header.Values(extract.Expr)
+		if len(values) == 0 {
+			return "", fmt.Errorf("header %s not found", extract.Expr)
+		}
+		return values[0], nil
+	case ExtractCookie:
+		for _, cookie := range client.Jar.Cookies(result.Response.Request.URL) {
+			if cookie.Name == extract.Expr {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie %s not found", extract.Expr)
+	}
+	return "", fmt.Errorf("unknown type: %s", extract.Type)
+}
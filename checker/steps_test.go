@@ -0,0 +1,325 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newScenarioServer はログインでクッキーとトークンを発行し、両方が揃った場合のみ /api が成功するサーバーです
+func newScenarioServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cret", Path: "/"})
+		w.Header().Set("X-Request-Id", "req-1")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data":{"token":"abc","ids":[7,8]},"html":"<input name='csrf' value='xyz'>"}`))
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie("session")
+		if err != nil || cookie.Value != "s3cret" || r.Header.Get("Authorization") != "Bearer abc" || r.Header.Get("X-CSRF-Token") != "xyz" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("item " + strings.TrimPrefix(r.URL.Path, "/api/") + " " + r.Header.Get("X-Request-Id")))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckScenario(t *testing.T) {
+	server := newScenarioServer(t)
+
+	config := &Config{
+		Name:    "scenario",
+		Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+		Steps: []StepConfig{
+			{
+				Name:    "login",
+				Method:  "POST",
+				URL:     server.URL + "/login",
+				Asserts: AssertsConfig{StatusCode: StatusCodeAssert{Values: []int{200}}},
+				Extract: []ExtractConfig{
+					{Name: "token", Type: ExtractJSON, Expr: "$.data.token"},
+					{Name: "id", Type: ExtractJSON, Expr: "$.data.ids[1]"},
+					{Name: "csrf", Type: ExtractRegex, Expr: `name='csrf' value='([^']+)'`},
+					{Name: "requestID", Type: ExtractHeader, Expr: "X-Request-Id"},
+					{Name: "session", Type: ExtractCookie, Expr: "session"},
+				},
+			},
+			{
+				URL: server.URL + "/api/{{.id}}",
+				Headers: map[string]string{
+					"Authorization": "Bearer {{.token}}",
+					"X-CSRF-Token":  "{{.csrf}}",
+					"X-Request-Id":  "{{.requestID}}-{{.session}}",
+				},
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{Values: []int{200}},
+					Body:       BodyAssert{Regex: "^item 8 req-1-s3cret$"},
+				},
+			},
+		},
+	}
+
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	result, err := checkOnce(context.Background(), config, client)
+	if err != nil {
+		t.Fatalf("checkOnce() error = %v", err)
+	}
+	if !result.Success() {
+		t.Fatalf("statusCode = %d, err = %v, assertErr = %v", result.StatusCode, result.Err, result.AssertErr)
+	}
+	if result.FailedStep != "" {
+		t.Errorf("failedStep = %q, want empty", result.FailedStep)
+	}
+	if len(result.Steps) != 2 || result.Steps[0].Name != "login" || result.Steps[1].Name != "#2" {
+		t.Fatalf("steps = %+v", result.Steps)
+	}
+	if result.Duration != result.Steps[0].Duration+result.Steps[1].Duration {
+		t.Errorf("duration = %v, want sum of steps", result.Duration)
+	}
+	if result.URL != server.URL+"/api/8" {
+		t.Errorf("finalURL = %q", result.URL)
+	}
+}
+
+func TestCheckScenarioFailure(t *testing.T) {
+	server := newScenarioServer(t)
+
+	tests := []struct {
+		name       string
+		extract    []ExtractConfig
+		wantStatus int
+		wantSteps  int
+		wantFailed string
+	}{
+		{
+			name:       "protected endpoint rejects",
+			extract:    []ExtractConfig{{Name: "token", Type: ExtractJSON, Expr: "$.data.token"}},
+			wantStatus: StatusAssertFailed,
+			wantSteps:  2,
+			wantFailed: "api",
+		},
+		{
+			name:       "extraction fails",
+			extract:    []ExtractConfig{{Name: "token", Type: ExtractJSON, Expr: "$.data.missing"}},
+			wantStatus: StatusAssertFailed,
+			wantSteps:  1,
+			wantFailed: "login",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+				Steps: []StepConfig{
+					{
+						Name:    "login",
+						Method:  "POST",
+						URL:     server.URL + "/login",
+						Asserts: AssertsConfig{StatusCode: StatusCodeAssert{Values: []int{200}}},
+						Extract: tt.extract,
+					},
+					{
+						Name:    "api",
+						URL:     server.URL + "/api/1",
+						Headers: map[string]string{"Authorization": "Bearer {{.token}}"},
+						Asserts: AssertsConfig{StatusCode: StatusCodeAssert{Values: []int{200}}},
+					},
+				},
+			}
+
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Errorf("statusCode = %d, want %d", result.StatusCode, tt.wantStatus)
+			}
+			if len(result.Steps) != tt.wantSteps {
+				t.Errorf("len(steps) = %d, want %d", len(result.Steps), tt.wantSteps)
+			}
+			if result.FailedStep != tt.wantFailed {
+				t.Errorf("failedStep = %q, want %q", result.FailedStep, tt.wantFailed)
+			}
+			if result.AssertErr == nil {
+				t.Errorf("assertErr = nil, want error")
+			}
+
+			var record jsonRecord
+			line, err := config.formatJSONL(result)
+			if err != nil {
+				t.Fatalf("formatJSONL() error = %v", err)
+			}
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("invalid JSON %q: %v", line, err)
+			}
+			if record.FailedStep != tt.wantFailed || len(record.Steps) != tt.wantSteps {
+				t.Errorf("record = %+v", record)
+			}
+			if last := record.Steps[len(record.Steps)-1]; last.Error != "ASSERT_FAILED" {
+				t.Errorf("last step error = %q, want ASSERT_FAILED", last.Error)
+			}
+		})
+	}
+}
+
+func TestCheckScenarioUndefinedVariable(t *testing.T) {
+	config := &Config{
+		Steps: []StepConfig{
+			{URL: "http://127.0.0.1/{{.missing}}"},
+		},
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	if _, err := checkOnce(context.Background(), config, client); err == nil {
+		t.Errorf("checkOnce() expected error for undefined variable")
+	}
+}
+
+func TestLoadConfigSteps(t *testing.T) {
+	content := `headers:
+  User-Agent: chechekule
+steps:
+  - name: login
+    url: https://example.com/login
+    extract:
+      - name: token
+        type: json
+        expr: $.token
+  - url: https://example.com/api
+    headers:
+      Authorization: Bearer {{.token}}
+    asserts:
+      status_code:
+        values: [204]
+`
+	tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	config, err := LoadConfig(tmpFile)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if len(config.Steps) != 2 {
+		t.Fatalf("len(Steps) = %d, want 2", len(config.Steps))
+	}
+	if got := config.Steps[0].Asserts.StatusCode.Values; len(got) != 1 || got[0] != 200 {
+		t.Errorf("default step status values = %v, want [200]", got)
+	}
+	if got := config.Steps[1].Asserts.StatusCode.Values; len(got) != 1 || got[0] != 204 {
+		t.Errorf("step status values = %v, want [204]", got)
+	}
+	if got := config.targetName(); got != "https://example.com/login" {
+		t.Errorf("targetName() = %q", got)
+	}
+
+	step, err := config.stepConfig(&config.Steps[1], map[string]string{"token": "abc"})
+	if err != nil {
+		t.Fatalf("stepConfig() error = %v", err)
+	}
+	if step.Headers["User-Agent"] != "chechekule" || step.Headers["Authorization"] != "Bearer abc" || step.URL != "https://example.com/api" {
+		t.Errorf("stepConfig() = %+v", step)
+	}
+}
+
+func TestLoadConfigStepsErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "step without url",
+			content: `steps:
+  - name: login`,
+		},
+		{
+			name: "unknown extractor",
+			content: `steps:
+  - url: https://example.com
+    extract:
+      - name: token
+        type: xpath
+        expr: //token`,
+		},
+		{
+			name: "invalid regex",
+			content: `steps:
+  - url: https://example.com
+    extract:
+      - name: token
+        type: regex
+        expr: "("`,
+		},
+		{
+			name: "malformed url template",
+			content: `steps:
+  - url: "https://example.com/{{.token"`,
+		},
+		{
+			name: "undefined variable",
+			content: `steps:
+  - url: https://example.com
+    headers:
+      Authorization: Bearer {{.token}}`,
+		},
+		{
+			name: "variable extracted by a later step",
+			content: `steps:
+  - url: https://example.com
+    body: '{"token":"{{.token}}"}'
+  - url: https://example.com/login
+    extract:
+      - name: token
+        type: json
+        expr: $.token`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(tmpFile, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write test config: %v", err)
+			}
+			if _, err := LoadConfig(tmpFile); err == nil {
+				t.Errorf("LoadConfig() expected error")
+			}
+		})
+	}
+}
+
+func TestFormatSteps(t *testing.T) {
+	got := formatSteps([]StepResult{
+		{Name: "login", StatusCode: 200, Duration: 12 * time.Millisecond},
+		{Name: "api", StatusCode: StatusAssertFailed, Duration: 3 * time.Millisecond},
+	})
+	if want := "login:200:12ms,api:ASSERT_FAILED:3ms"; got != want {
+		t.Errorf("formatSteps() = %q, want %q", got, want)
+	}
+}
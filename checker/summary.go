@@ -0,0 +1,212 @@
+package checker
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// latencySamples はパーセンタイルの計算のために保持する応答時間の最大数です。
+// 長時間の監視でもメモリが増え続けないよう、超えた分はリザーバーサンプリングで置き換えます
+const latencySamples = 10000
+
+// targetStats はターゲットごとのチェック結果の統計です
+type targetStats struct {
+	total        int
+	success      int
+	statusCounts map[string]int
+	errorCounts  map[string]int
+
+	// 最小・最大・合計は全てのチェックから、パーセンタイルは durations のサンプルから求めます
+	minDuration time.Duration
+	maxDuration time.Duration
+	sumDuration time.Duration
+	durations   []time.Duration
+
+	// 継続中の障害と最長の障害
+	outageStart  time.Time
+	lastFailure  time.Time
+	longestStart time.Time
+	longestEnd   time.Time
+}
+
+func (s *targetStats) closeOutage(end time.Time) {
+	if s.outageStart.IsZero() {
+		return
+	}
+	if end.Sub(s.outageStart) > s.longestEnd.Sub(s.longestStart) {
+		s.longestStart = s.outageStart
+		s.longestEnd = end
+	}
+	s.outageStart = time.Time{}
+}
+
+func newTargetStats() *targetStats {
+	return &targetStats{
+		statusCounts: make(map[string]int),
+		errorCounts:  make(map[string]int),
+	}
+}
+
+// Summary は終了時に ping のような統計を出力するための集計です。fan_out のターゲットは IP ごとに集計します
+type Summary struct {
+	mu      sync.Mutex
+	targets []*Config
+	stats   map[targetKey]*targetStats
+}
+
+// NewSummary は config のターゲットを集計する Summary を作成します
+func NewSummary(config *Config) *Summary {
+	s := &Summary{
+		targets: config.CheckTargets(),
+		stats:   make(map[targetKey]*targetStats),
+	}
+	for _, target := range s.targets {
+		s.stats[targetKey{target: target}] = newTargetStats()
+	}
+	return s
+}
+
+func (s *Summary) Write(target *Config, result *Result) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.stats[targetKey{target: target}]; !ok {
+		return
+	}
+	key := targetKey{target: target, ip: result.IP}
+	stats, ok := s.stats[key]
+	if !ok {
+		stats = newTargetStats()
+		s.stats[key] = stats
+	}
+
+	stats.total++
+	stats.addDuration(result.Duration)
+	if result.StatusCode < 0 {
+		stats.errorCounts[result.StatusText()]++
+	} else if result.Response != nil {
+		stats.statusCounts[fmt.Sprint(result.Response.StatusCode)]++
+	}
+
+	if result.Success() {
+		stats.success++
+		// 障害は次に成功したチェックの時刻で終わったとみなします
+		stats.closeOutage(result.RequestedAt)
+		return
+	}
+	if stats.outageStart.IsZero() {
+		stats.outageStart = result.RequestedAt
+	}
+	stats.lastFailure = result.RequestedAt
+}
+
+// addDuration は応答時間を集計します。サンプルが上限に達した後は、全てのチェックが等しい確率で残るように置き換えます
+func (s *targetStats) addDuration(d time.Duration) {
+	if s.total == 1 || d < s.minDuration {
+		s.minDuration = d
+	}
+	if d > s.maxDuration {
+		s.maxDuration = d
+	}
+	s.sumDuration += d
+
+	if len(s.durations) < latencySamples {
+		s.durations = append(s.durations, d)
+		return
+	}
+	if i := rand.IntN(s.total); i < latencySamples {
+		s.durations[i] = d
+	}
+}
+
+// percentile は昇順にソート済みの値から nearest-rank 法でパーセンタイルを求めます
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func formatCounts(counts map[string]int) string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	return strings.Join(parts, " ")
+}
+
+// Print はターゲットごとの統計を書き出します
+func (s *Summary) Print(w io.Writer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]targetKey, 0, len(s.stats))
+	for key := range s.stats {
+		keys = append(keys, key)
+	}
+	keys = orderedTargetKeys(s.targets, keys, func(key targetKey) bool {
+		return s.stats[key].total > 0
+	})
+
+	for _, key := range keys {
+		stats := s.stats[key]
+		fmt.Fprintf(w, "\n--- %s statistics ---\n", key.displayName())
+
+		failed := stats.total - stats.success
+		uptime := 0.0
+		if stats.total > 0 {
+			uptime = float64(stats.success) / float64(stats.total) * 100
+		}
+		fmt.Fprintf(w, "%d checks, %d succeeded, %d failed, %.2f%% uptime\n", stats.total, stats.success, failed, uptime)
+		if stats.total == 0 {
+			continue
+		}
+
+		if len(stats.statusCounts) > 0 {
+			fmt.Fprintf(w, "status codes: %s\n", formatCounts(stats.statusCounts))
+		}
+		if len(stats.errorCounts) > 0 {
+			fmt.Fprintf(w, "errors: %s\n", formatCounts(stats.errorCounts))
+		}
+
+		sorted := append([]time.Duration(nil), stats.durations...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		fmt.Fprintf(w, "latency min/avg/max/p50/p90/p99 = %v/%v/%v/%v/%v/%v\n",
+			stats.minDuration, stats.sumDuration/time.Duration(stats.total), stats.maxDuration,
+			percentile(sorted, 50), percentile(sorted, 90), percentile(sorted, 99))
+
+		// 終了時点で継続中の障害は最後に失敗したチェックまでを期間とします
+		longestStart, longestEnd := stats.longestStart, stats.longestEnd
+		ongoing := false
+		if !stats.outageStart.IsZero() && stats.lastFailure.Sub(stats.outageStart) >= longestEnd.Sub(longestStart) {
+			longestStart, longestEnd = stats.outageStart, stats.lastFailure
+			ongoing = true
+		}
+		if !longestStart.IsZero() {
+			suffix := ""
+			if ongoing {
+				suffix = ", ongoing"
+			}
+			fmt.Fprintf(w, "longest outage: %v (%s - %s%s)\n", longestEnd.Sub(longestStart),
+				longestStart.Format("2006-01-02T15:04:05.000Z07:00"), longestEnd.Format("2006-01-02T15:04:05.000Z07:00"), suffix)
+		}
+	}
+}
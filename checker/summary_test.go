@@ -0,0 +1,123 @@
+package checker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPercentile(t *testing.T) {
+	sorted := make([]time.Duration, 100)
+	for i := range sorted {
+		sorted[i] = time.Duration(i+1) * time.Millisecond
+	}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 50, want: 50 * time.Millisecond},
+		{p: 90, want: 90 * time.Millisecond},
+		{p: 99, want: 99 * time.Millisecond},
+		{p: 100, want: 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if got := percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Errorf("percentile(nil) = %v, want 0", got)
+	}
+}
+
+func TestSummaryWrite(t *testing.T) {
+	target := &Config{Name: "api", URL: "https://example.com"}
+	s := NewSummary(target)
+
+	base := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	ok := func(sec int, d time.Duration) *Result {
+		return &Result{RequestedAt: base.Add(time.Duration(sec) * time.Second), StatusCode: 200, Duration: d, Response: &http.Response{StatusCode: 200}}
+	}
+	failed := func(sec int, status int) *Result {
+		return &Result{RequestedAt: base.Add(time.Duration(sec) * time.Second), StatusCode: status, Duration: 10 * time.Millisecond, Err: errors.New("failed")}
+	}
+
+	for _, r := range []*Result{
+		ok(0, 10*time.Millisecond),
+		failed(1, StatusConnectionFailed),
+		ok(2, 20*time.Millisecond),
+		failed(3, StatusTimeout),
+		failed(4, StatusTimeout),
+		failed(5, StatusConnectionFailed),
+		ok(6, 30*time.Millisecond),
+		failed(7, StatusTimeout),
+	} {
+		s.Write(target, r)
+	}
+
+	var buf bytes.Buffer
+	s.Print(&buf)
+	got := buf.String()
+
+	for _, want := range []string{
+		"--- api statistics ---",
+		"8 checks, 3 succeeded, 5 failed, 37.50% uptime",
+		"status codes: 200=3",
+		"errors: CONNECTION_FAILED=2 TIMEOUT=3",
+		"latency min/avg/max/p50/p90/p99 = 10ms/13.75ms/30ms/10ms/30ms/30ms",
+		"longest outage: 3s (2024-01-02T03:04:03.000Z - 2024-01-02T03:04:06.000Z)",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary does not contain %q\n%s", want, got)
+		}
+	}
+}
+
+func TestSummaryLatencySamples(t *testing.T) {
+	target := &Config{Name: "api", URL: "https://example.com"}
+	s := NewSummary(target)
+
+	// 上限を超えてもサンプルは増えず、最小・最大・平均は全てのチェックから求めること
+	checks := latencySamples + 500
+	for i := 1; i <= checks; i++ {
+		s.Write(target, &Result{StatusCode: 200, Duration: time.Duration(i) * time.Microsecond})
+	}
+
+	stats := s.stats[targetKey{target: target}]
+	if len(stats.durations) != latencySamples {
+		t.Errorf("len(durations) = %d, want %d", len(stats.durations), latencySamples)
+	}
+
+	var buf bytes.Buffer
+	s.Print(&buf)
+	want := fmt.Sprintf("latency min/avg/max/p50/p90/p99 = 1µs/%v/%v/", time.Duration(checks+1)*time.Microsecond/2, time.Duration(checks)*time.Microsecond)
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("summary does not contain %q\n%s", want, buf.String())
+	}
+}
+
+func TestSummaryOngoingOutage(t *testing.T) {
+	target := &Config{URL: "https://example.com"}
+	s := NewSummary(target)
+
+	base := time.Date(2024, 1, 2, 3, 4, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		s.Write(target, &Result{RequestedAt: base.Add(time.Duration(i) * time.Second), StatusCode: StatusConnectionFailed, Duration: time.Millisecond})
+	}
+
+	var buf bytes.Buffer
+	s.Print(&buf)
+	got := buf.String()
+
+	if !strings.Contains(got, "--- https://example.com statistics ---") {
+		t.Errorf("summary should fall back to the url as the name\n%s", got)
+	}
+	if !strings.Contains(got, "longest outage: 2s (2024-01-02T03:04:00.000Z - 2024-01-02T03:04:02.000Z, ongoing)") {
+		t.Errorf("summary should report the ongoing outage\n%s", got)
+	}
+}
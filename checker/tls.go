@@ -0,0 +1,234 @@
+package checker
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// TLSConfig はクライアント側の TLS 設定です
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	ServerName         string `yaml:"server_name"`
+	MinVersion         string `yaml:"min_version"`
+	MaxVersion         string `yaml:"max_version"`
+}
+
+func (t *TLSConfig) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("tls.cert_file and tls.key_file must be set together")
+	}
+	var minVersion, maxVersion uint16
+	var err error
+	if t.MinVersion != "" {
+		if minVersion, err = parseTLSVersion(t.MinVersion); err != nil {
+			return fmt.Errorf("tls.min_version: %w", err)
+		}
+	}
+	if t.MaxVersion != "" {
+		if maxVersion, err = parseTLSVersion(t.MaxVersion); err != nil {
+			return fmt.Errorf("tls.max_version: %w", err)
+		}
+	}
+	if minVersion != 0 && maxVersion != 0 && minVersion > maxVersion {
+		return fmt.Errorf("tls.min_version must not be greater than tls.max_version")
+	}
+	return nil
+}
+
+// clientConfig は Transport に設定する tls.Config を作成します。
+// ca_file の証明書はシステムのルート証明書に追加して使います
+func (t *TLSConfig) clientConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.ServerName,
+	}
+
+	if t.CAFile != "" {
+		data, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in CA file: %s", t.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	var err error
+	if t.MinVersion != "" {
+		if config.MinVersion, err = parseTLSVersion(t.MinVersion); err != nil {
+			return nil, err
+		}
+	}
+	if t.MaxVersion != "" {
+		if config.MaxVersion, err = parseTLSVersion(t.MaxVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+// tlsVersions は設定で指定できる TLS のバージョンです
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// parseTLSVersion は "1.2" や "TLS1.3" のような表記をバージョン番号に変換します
+func parseTLSVersion(s string) (uint16, error) {
+	v := strings.TrimPrefix(strings.TrimPrefix(strings.ToUpper(s), "TLS"), "V")
+	version, ok := tlsVersions[strings.TrimSpace(v)]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version: %s", s)
+	}
+	return version, nil
+}
+
+// TLSInfo はレスポンスの TLS 接続とサーバー証明書(リーフ)の情報です
+type TLSInfo struct {
+	Version       string
+	Cipher        string
+	NotAfter      time.Time
+	Issuer        string
+	SANs          []string
+	DaysRemaining int
+}
+
+// newTLSInfo は接続状態から証明書の情報を取り出します。残り日数は now からの切り捨てです
+func newTLSInfo(state *tls.ConnectionState, now time.Time) *TLSInfo {
+	info := &TLSInfo{
+		Version: tls.VersionName(state.Version),
+		Cipher:  tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		info.NotAfter = leaf.NotAfter
+		info.Issuer = leaf.Issuer.String()
+		info.SANs = append(info.SANs, leaf.DNSNames...)
+		for _, ip := range leaf.IPAddresses {
+			info.SANs = append(info.SANs, ip.String())
+		}
+		info.DaysRemaining = daysRemaining(leaf.NotAfter, now)
+	}
+	return info
+}
+
+// daysRemaining は notAfter までの日数です。切り捨てるため、期限を数時間でも過ぎた証明書は負の値になります
+func daysRemaining(notAfter, now time.Time) int {
+	return int(math.Floor(notAfter.Sub(now).Hours() / 24))
+}
+
+// TLSAssert はサーバー証明書と TLS 接続を検証します
+type TLSAssert struct {
+	MinDaysRemaining int      `yaml:"min_days_remaining"`
+	MinVersion       string   `yaml:"min_version"`
+	HostnameMatches  bool     `yaml:"hostname_matches"`
+	Pins             []string `yaml:"pins"`
+}
+
+// enabled はいずれかの検証が設定されているかどうかを返します
+func (a *TLSAssert) enabled() bool {
+	return a.MinDaysRemaining != 0 || a.MinVersion != "" || a.HostnameMatches || len(a.Pins) > 0
+}
+
+func (a *TLSAssert) validate() error {
+	if a.MinDaysRemaining < 0 {
+		return fmt.Errorf("asserts.tls.min_days_remaining must not be negative")
+	}
+	if a.MinVersion != "" {
+		if _, err := parseTLSVersion(a.MinVersion); err != nil {
+			return fmt.Errorf("asserts.tls.min_version: %w", err)
+		}
+	}
+	for i, pin := range a.Pins {
+		hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("asserts.tls.pins[%d]: expected sha256/<base64 SHA-256 of the public key>", i)
+		}
+	}
+	return nil
+}
+
+// check は TLS 接続でないレスポンスや、条件を満たさない証明書をエラーにします
+func (a *TLSAssert) check(resp *http.Response, now time.Time) error {
+	if !a.enabled() {
+		return nil
+	}
+	if resp.TLS == nil || len(resp.TLS.PeerCertificates) == 0 {
+		return fmt.Errorf("tls: response was not received over TLS")
+	}
+	state := resp.TLS
+	leaf := state.PeerCertificates[0]
+
+	if a.MinDaysRemaining > 0 {
+		days := daysRemaining(leaf.NotAfter, now)
+		if days < a.MinDaysRemaining {
+			return fmt.Errorf("tls: certificate expires in %d days (%s), expected at least %d", days, leaf.NotAfter.Format(time.RFC3339), a.MinDaysRemaining)
+		}
+	}
+	if a.MinVersion != "" {
+		minVersion, err := parseTLSVersion(a.MinVersion)
+		if err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+		if state.Version < minVersion {
+			return fmt.Errorf("tls: negotiated %s, expected at least %s", tls.VersionName(state.Version), tls.VersionName(minVersion))
+		}
+	}
+	if a.HostnameMatches {
+		hostname := resp.Request.URL.Hostname()
+		if err := leaf.VerifyHostname(hostname); err != nil {
+			return fmt.Errorf("tls: %w", err)
+		}
+	}
+	if len(a.Pins) > 0 {
+		// チェーン内のいずれかの証明書の公開鍵がピンと一致すれば成功です
+		matched := false
+		var actual []string
+		for _, cert := range state.PeerCertificates {
+			pin := spkiPin(cert.RawSubjectPublicKeyInfo)
+			actual = append(actual, pin)
+			for _, expected := range a.Pins {
+				if pin == "sha256/"+strings.TrimPrefix(expected, "sha256/") {
+					matched = true
+				}
+			}
+		}
+		if !matched {
+			return fmt.Errorf("tls: no certificate matches pins %v, got %v", a.Pins, actual)
+		}
+	}
+	return nil
+}
+
+// spkiPin は公開鍵(SubjectPublicKeyInfo)の SHA-256 を HPKP と同じ "sha256/<base64>" 形式で返します
+func spkiPin(rawSubjectPublicKeyInfo []byte) string {
+	hash := sha256.Sum256(rawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(hash[:])
+}
@@ -0,0 +1,419 @@
+package checker
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTLSTestClient は httptest の TLS サーバーを信頼するクライアントを作成します
+func newTLSTestClient(t *testing.T, config *Config, server *httptest.Server) *http.Client {
+	t.Helper()
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client.Transport.(*http.Transport).TLSClientConfig = &tls.Config{RootCAs: pool}
+	return client
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	tests := []struct {
+		input string
+		want  uint16
+	}{
+		{input: "1.2", want: tls.VersionTLS12},
+		{input: "TLS1.3", want: tls.VersionTLS13},
+		{input: "tlsv1.0", want: tls.VersionTLS10},
+	}
+	for _, tt := range tests {
+		got, err := parseTLSVersion(tt.input)
+		if err != nil || got != tt.want {
+			t.Errorf("parseTLSVersion(%q) = %x, %v, want %x", tt.input, got, err, tt.want)
+		}
+	}
+	if _, err := parseTLSVersion("1.4"); err == nil {
+		t.Errorf("parseTLSVersion(1.4) expected error")
+	}
+}
+
+func TestTLSInfo(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	config := &Config{
+		Name:    "tls",
+		URL:     server.URL,
+		Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+		Asserts: AssertsConfig{StatusCode: StatusCodeAssert{Values: []int{200}}},
+	}
+	result, err := checkOnce(context.Background(), config, newTLSTestClient(t, config, server))
+	if err != nil {
+		t.Fatalf("checkOnce() error = %v", err)
+	}
+	if !result.Success() {
+		t.Fatalf("statusCode = %d, err = %v", result.StatusCode, result.Err)
+	}
+	if result.TLS == nil {
+		t.Fatalf("tls = nil")
+	}
+
+	cert := server.Certificate()
+	if result.TLS.Version != "TLS 1.3" || result.TLS.Cipher == "" {
+		t.Errorf("version = %q, cipher = %q", result.TLS.Version, result.TLS.Cipher)
+	}
+	if !result.TLS.NotAfter.Equal(cert.NotAfter) || result.TLS.Issuer != cert.Issuer.String() {
+		t.Errorf("notAfter = %v, issuer = %q", result.TLS.NotAfter, result.TLS.Issuer)
+	}
+	if got := strings.Join(result.TLS.SANs, ","); got != strings.Join(cert.DNSNames, ",")+",127.0.0.1,::1" {
+		t.Errorf("sans = %q", got)
+	}
+	wantDays := int(time.Until(cert.NotAfter).Hours() / 24)
+	if result.TLS.DaysRemaining < wantDays-1 || result.TLS.DaysRemaining > wantDays {
+		t.Errorf("daysRemaining = %d, want %d", result.TLS.DaysRemaining, wantDays)
+	}
+
+	data := config.templateData(result)
+	if data["tlsDaysRemaining"] != result.TLS.DaysRemaining || data["tlsVersion"] != "TLS 1.3" {
+		t.Errorf("templateData() = %v", data)
+	}
+	record := config.newJSONRecord(result)
+	if record.TLS == nil || record.TLS.DaysRemaining == nil || *record.TLS.DaysRemaining != result.TLS.DaysRemaining {
+		t.Errorf("record.TLS = %+v", record.TLS)
+	}
+
+	m := newMetrics([]*Config{config})
+	m.Write(config, result)
+	var buf strings.Builder
+	m.render(&buf)
+	if want := `chechekule_tls_cert_days_remaining{target="tls"} `; !strings.Contains(buf.String(), want) {
+		t.Errorf("metrics missing %q:\n%s", want, buf.String())
+	}
+}
+
+func TestDaysRemaining(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		notAfter time.Time
+		want     int
+	}{
+		{notAfter: now.Add(30*24*time.Hour + time.Hour), want: 30},
+		{notAfter: now.Add(23 * time.Hour), want: 0},
+		// 期限を数時間過ぎた証明書は 0 ではなく負の値にすること
+		{notAfter: now.Add(-3 * time.Hour), want: -1},
+		{notAfter: now.Add(-49 * time.Hour), want: -3},
+	}
+
+	for _, tt := range tests {
+		if got := daysRemaining(tt.notAfter, now); got != tt.want {
+			t.Errorf("daysRemaining(%v) = %d, want %d", tt.notAfter.Sub(now), got, tt.want)
+		}
+	}
+}
+
+func TestTLSAssert(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	plainServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer plainServer.Close()
+
+	pin := spkiPin(server.Certificate().RawSubjectPublicKeyInfo)
+	otherPin := "sha256/" + strings.Repeat("A", 43) + "="
+
+	tests := []struct {
+		name     string
+		url      string
+		insecure bool
+		assert   TLSAssert
+		wantErr  string
+	}{
+		{
+			name:   "all pass",
+			url:    server.URL,
+			assert: TLSAssert{MinDaysRemaining: 30, MinVersion: "1.2", HostnameMatches: true, Pins: []string{otherPin, pin}},
+		},
+		{
+			name:    "expires soon",
+			url:     server.URL,
+			assert:  TLSAssert{MinDaysRemaining: 1000000},
+			wantErr: "expected at least 1000000",
+		},
+		{
+			name:    "pin mismatch",
+			url:     server.URL,
+			assert:  TLSAssert{Pins: []string{otherPin}},
+			wantErr: "no certificate matches pins",
+		},
+		{
+			name:     "hostname mismatch",
+			url:      strings.Replace(server.URL, "127.0.0.1", "localhost", 1),
+			insecure: true,
+			assert:   TLSAssert{HostnameMatches: true},
+			wantErr:  "localhost",
+		},
+		{
+			name:    "plain http",
+			url:     plainServer.URL,
+			assert:  TLSAssert{MinDaysRemaining: 1},
+			wantErr: "not received over TLS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				URL:     tt.url,
+				Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{Values: []int{200}},
+					TLS:        tt.assert,
+				},
+			}
+			client := newTLSTestClient(t, config, server)
+			client.Transport.(*http.Transport).TLSClientConfig.InsecureSkipVerify = tt.insecure
+
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if tt.wantErr == "" {
+				if !result.Success() {
+					t.Errorf("statusCode = %d, err = %v, assertErr = %v", result.StatusCode, result.Err, result.AssertErr)
+				}
+				return
+			}
+			if result.StatusCode != StatusAssertFailed {
+				t.Fatalf("statusCode = %d, want %d (err = %v)", result.StatusCode, StatusAssertFailed, result.Err)
+			}
+			if !strings.Contains(result.AssertErr.Error(), tt.wantErr) {
+				t.Errorf("assertErr = %v, want %q", result.AssertErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTLSAssertValidate(t *testing.T) {
+	tests := []TLSAssert{
+		{MinDaysRemaining: -1},
+		{MinVersion: "2.0"},
+		{Pins: []string{"sha256/not-base64"}},
+		{Pins: []string{"sha256/AAAA"}},
+	}
+	for _, assert := range tests {
+		if err := assert.validate(); err == nil {
+			t.Errorf("validate(%+v) expected error", assert)
+		}
+	}
+}
+
+// writePEM は DER を PEM としてファイルに書き出し、そのパスを返します
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// newClientCertificate はテスト用の CA と、その CA が署名したクライアント証明書を作成します。
+// CA 証明書とクライアント証明書・鍵のファイルパスを返します
+func newClientCertificate(t *testing.T) (ca *x509.Certificate, certFile, keyFile string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "chechekule test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create CA: %v", err)
+	}
+	if ca, err = x509.ParseCertificate(caDER); err != nil {
+		t.Fatalf("Failed to parse CA: %v", err)
+	}
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	clientTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "chechekule"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, clientTemplate, ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create client certificate: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return ca, writePEM(t, "client.crt", "CERTIFICATE", clientDER), writePEM(t, "client.key", "EC PRIVATE KEY", keyDER)
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	clientCA, certFile, keyFile := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCA)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, "ca.crt", "CERTIFICATE", server.Certificate().Raw)
+
+	tests := []struct {
+		name        string
+		tls         TLSConfig
+		wantStatus  int
+		wantVersion string
+	}{
+		{
+			name:        "mutual TLS",
+			tls:         TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile},
+			wantStatus:  200,
+			wantVersion: "TLS 1.3",
+		},
+		{
+			name:       "untrusted server",
+			tls:        TLSConfig{CertFile: certFile, KeyFile: keyFile},
+			wantStatus: StatusCertificateInvalid,
+		},
+		{
+			name:        "insecure skip verify",
+			tls:         TLSConfig{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile},
+			wantStatus:  200,
+			wantVersion: "TLS 1.3",
+		},
+		{
+			name:        "server name override",
+			tls:         TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "example.com"},
+			wantStatus:  200,
+			wantVersion: "TLS 1.3",
+		},
+		{
+			name:       "server name mismatch",
+			tls:        TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "wrong.test"},
+			wantStatus: StatusCertificateInvalid,
+		},
+		{
+			name:        "max version",
+			tls:         TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", MaxVersion: "1.2"},
+			wantStatus:  200,
+			wantVersion: "TLS 1.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				URL:      server.URL,
+				Interval: time.Second,
+				Timeout:  TimeoutConfig{Connect: time.Second, Read: time.Second},
+				TLS:      tt.tls,
+				Asserts: AssertsConfig{
+					StatusCode: StatusCodeAssert{Values: []int{200}},
+					Body:       BodyAssert{Regex: "^chechekule$"},
+				},
+			}
+			if err := config.Validate(); err != nil {
+				t.Fatalf("validate() error = %v", err)
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if result.StatusCode != tt.wantStatus {
+				t.Fatalf("statusCode = %d, want %d (err = %v, assertErr = %v)", result.StatusCode, tt.wantStatus, result.Err, result.AssertErr)
+			}
+			if tt.wantVersion != "" && result.TLS.Version != tt.wantVersion {
+				t.Errorf("tls version = %q, want %q", result.TLS.Version, tt.wantVersion)
+			}
+		})
+	}
+
+	// クライアント証明書が無い場合はサーバーに拒否されます
+	config := &Config{
+		URL:     server.URL,
+		Timeout: TimeoutConfig{Connect: time.Second, Read: time.Second},
+		TLS:     TLSConfig{CAFile: caFile},
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	result, err := checkOnce(context.Background(), config, client)
+	if err != nil {
+		t.Fatalf("checkOnce() error = %v", err)
+	}
+	if result.StatusCode != StatusTLSHandshakeFailed {
+		t.Errorf("statusCode = %d, want %d without a client certificate (err = %v)", result.StatusCode, StatusTLSHandshakeFailed, result.Err)
+	}
+}
+
+func TestTLSConfigErrors(t *testing.T) {
+	invalid := filepath.Join(t.TempDir(), "invalid.pem")
+	if err := os.WriteFile(invalid, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	validateTests := []TLSConfig{
+		{CertFile: "client.crt"},
+		{MinVersion: "1.5"},
+		{MinVersion: "1.3", MaxVersion: "1.2"},
+	}
+	for _, config := range validateTests {
+		if err := config.validate(); err == nil {
+			t.Errorf("validate(%+v) expected error", config)
+		}
+	}
+
+	clientConfigTests := []TLSConfig{
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+		{CAFile: invalid},
+		{CertFile: invalid, KeyFile: invalid},
+	}
+	for _, config := range clientConfigTests {
+		if _, err := config.clientConfig(); err == nil {
+			t.Errorf("clientConfig(%+v) expected error", config)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestPhase はリクエストがどの段階まで進んだかを表します
+type requestPhase int32
+
+const (
+	phaseConnect requestPhase = iota
+	phaseTLSHandshake
+	phaseResponseHeader
+	phaseBodyRead
+)
+
+// フェーズごとのタイムアウト時のステータス
+var phaseTimeoutStatus = map[requestPhase]int{
+	phaseConnect:        StatusConnectTimeout,
+	phaseTLSHandshake:   StatusTLSHandshakeTimeout,
+	phaseResponseHeader: StatusResponseHeaderTimeout,
+	phaseBodyRead:       StatusReadTimeout,
+}
+
+// Timings はフェーズごとの所要時間です。リダイレクトした場合は最後のリクエストの値になります
+type Timings struct {
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	TTFB     time.Duration
+	Transfer time.Duration
+}
+
+// requestTrace は httptrace のフックでリクエストの進行状況と各フェーズの時刻を記録します。
+// ダイヤルは別の goroutine で行われるため、ロックして扱います
+type requestTrace struct {
+	mu           sync.Mutex
+	phase        requestPhase
+	getConn      time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	bodyDone     time.Time
+	remoteAddr   string
+	connReused   bool
+}
+
+func (t *requestTrace) currentPhase() requestPhase {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.phase
+}
+
+// record はロックを取って現在時刻を書き込みます
+func (t *requestTrace) record(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	*field = time.Now()
+}
+
+func (t *requestTrace) withContext(ctx context.Context) context.Context {
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		// リダイレクトのたびに接続からやり直します
+		GetConn: func(hostPort string) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phase = phaseConnect
+			t.getConn = time.Now()
+			t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+			t.connectStart, t.connectDone = time.Time{}, time.Time{}
+			t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			t.firstByte, t.bodyDone = time.Time{}, time.Time{}
+			t.remoteAddr = ""
+			t.connReused = false
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			t.record(&t.dnsStart)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.record(&t.dnsDone)
+		},
+		ConnectStart: func(network, addr string) {
+			// Happy Eyeballs で複数回呼ばれるため最初の時刻を残します
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(network, addr string, err error) {
+			// 接続できたアドレスを優先し、すべて失敗した場合は最後に試したアドレスを残します
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			if err == nil {
+				t.connectDone = time.Now()
+				t.remoteAddr = addr
+			} else if t.connectDone.IsZero() {
+				t.remoteAddr = addr
+			}
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phase = phaseTLSHandshake
+			t.tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(state tls.ConnectionState, err error) {
+			t.record(&t.tlsDone)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phase = phaseResponseHeader
+			t.connReused = info.Reused
+			// 再利用した接続では ConnectDone が呼ばれないため、接続先をここで記録します
+			if info.Reused && info.Conn != nil {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+			}
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.phase = phaseBodyRead
+			t.firstByte = time.Now()
+		},
+	})
+}
+
+// remoteIP は接続先の IP アドレスです。プロキシを使う場合はプロキシのアドレスになります
+func (t *requestTrace) remoteIP() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	host, _, err := net.SplitHostPort(t.remoteAddr)
+	if err != nil {
+		return t.remoteAddr
+	}
+	return host
+}
+
+// reused は keep-alive で既存の接続を再利用したかどうかを返します
+func (t *requestTrace) reused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.connReused
+}
+
+// finishBody はボディを読み終えた時刻を記録します
+func (t *requestTrace) finishBody() {
+	t.record(&t.bodyDone)
+}
+
+func between(start, end time.Time) time.Duration {
+	if start.IsZero() || end.IsZero() {
+		return 0
+	}
+	return end.Sub(start)
+}
+
+func (t *requestTrace) timings() Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Timings{
+		DNS:      between(t.dnsStart, t.dnsDone),
+		Connect:  between(t.connectStart, t.connectDone),
+		TLS:      between(t.tlsStart, t.tlsDone),
+		TTFB:     between(t.getConn, t.firstByte),
+		Transfer: between(t.firstByte, t.bodyDone),
+	}
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
+
+// getRequestErrorStatus はタイムアウトであれば発生したフェーズに応じたステータスを返します。
+// TLS ハンドシェイク中の分類できないエラーはハンドシェイク失敗として扱います
+func getRequestErrorStatus(err error, phase requestPhase) int {
+	status := getErrorStatus(err)
+	switch {
+	case status == StatusTimeout:
+		if phaseStatus, ok := phaseTimeoutStatus[phase]; ok {
+			return phaseStatus
+		}
+	case status == StatusUnknown && phase == phaseTLSHandshake:
+		return StatusTLSHandshakeFailed
+	case status == StatusEmptyResponse && phase == phaseBodyRead:
+		// ボディの途中で切断された場合は空レスポンスではありません
+		return StatusConnectionReset
+	}
+	return status
+}
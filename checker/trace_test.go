@@ -0,0 +1,303 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGetRequestErrorStatus(t *testing.T) {
+	timeoutErr := &net.OpError{Op: "dial", Net: "tcp", Err: os.ErrDeadlineExceeded}
+
+	tests := []struct {
+		name     string
+		err      error
+		phase    requestPhase
+		expected int
+	}{
+		{
+			name:     "connect timeout",
+			err:      timeoutErr,
+			phase:    phaseConnect,
+			expected: StatusConnectTimeout,
+		},
+		{
+			name:     "tls handshake timeout",
+			err:      timeoutErr,
+			phase:    phaseTLSHandshake,
+			expected: StatusTLSHandshakeTimeout,
+		},
+		{
+			name:     "response header timeout",
+			err:      fmt.Errorf("wrapped: %w", context.DeadlineExceeded),
+			phase:    phaseResponseHeader,
+			expected: StatusResponseHeaderTimeout,
+		},
+		{
+			name:     "read timeout",
+			err:      timeoutErr,
+			phase:    phaseBodyRead,
+			expected: StatusReadTimeout,
+		},
+		{
+			name:     "not a timeout",
+			err:      errors.New("some other error"),
+			phase:    phaseBodyRead,
+			expected: StatusUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRequestErrorStatus(tt.err, tt.phase); got != tt.expected {
+				t.Errorf("getRequestErrorStatus() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPhaseTimeouts(t *testing.T) {
+	// TLS ハンドシェイクに応答しない TCP サーバー
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	headerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer headerServer.Close()
+
+	bodyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		time.Sleep(500 * time.Millisecond)
+		w.Write([]byte("rest"))
+	}))
+	defer bodyServer.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		expected int
+	}{
+		{
+			name:     "tls handshake timeout",
+			url:      "https://" + listener.Addr().String(),
+			expected: StatusTLSHandshakeTimeout,
+		},
+		{
+			name:     "response header timeout",
+			url:      headerServer.URL,
+			expected: StatusResponseHeaderTimeout,
+		},
+		{
+			name:     "read timeout",
+			url:      bodyServer.URL,
+			expected: StatusReadTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &Config{
+				URL: tt.url,
+				Timeout: TimeoutConfig{
+					Connect:      1 * time.Second,
+					TLSHandshake: 100 * time.Millisecond,
+					Read:         100 * time.Millisecond,
+				},
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+
+			result, err := checkOnce(context.Background(), config, client)
+			if err != nil {
+				t.Fatalf("checkOnce() error = %v", err)
+			}
+			if result.StatusCode != tt.expected {
+				t.Errorf("statusCode = %v (%v), want %v", result.StatusCode, result.Err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestReadTimeoutDisabled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("rest"))
+	}))
+	defer server.Close()
+
+	// read が 0 の場合はレスポンスヘッダーの待ち時間と同様にボディの読み込みも打ち切らないこと
+	config := &Config{
+		URL:     server.URL,
+		Timeout: TimeoutConfig{Connect: time.Second},
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	result, err := checkOnce(context.Background(), config, client)
+	if err != nil {
+		t.Fatalf("checkOnce() error = %v", err)
+	}
+	if result.StatusCode != http.StatusOK || string(result.Body) != "partialrest" {
+		t.Errorf("statusCode = %v (%v), body = %q, want 200 with the whole body", result.StatusCode, result.Err, result.Body)
+	}
+}
+
+func TestTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("rest"))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		URL: strings.Replace(server.URL, "127.0.0.1", "localhost", 1),
+		Timeout: TimeoutConfig{
+			Connect: 1 * time.Second,
+			Read:    1 * time.Second,
+		},
+	}
+	client, err := newClient(config, nil)
+	if err != nil {
+		t.Fatalf("newClient() error = %v", err)
+	}
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	tlsConfig.ServerName = "127.0.0.1"
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+
+	result, err := checkOnce(context.Background(), config, client)
+	if err != nil {
+		t.Fatalf("checkOnce() error = %v", err)
+	}
+	if result.Err != nil {
+		t.Fatalf("request failed: %v", result.Err)
+	}
+
+	timings := result.Timings
+	if timings.DNS <= 0 || timings.Connect <= 0 || timings.TLS <= 0 {
+		t.Errorf("Expected dns/connect/tls timings to be recorded, got %+v", timings)
+	}
+	if timings.TTFB < 20*time.Millisecond {
+		t.Errorf("TTFB = %v, want >= 20ms", timings.TTFB)
+	}
+	if timings.Transfer < 20*time.Millisecond {
+		t.Errorf("Transfer = %v, want >= 20ms", timings.Transfer)
+	}
+	if result.Duration < timings.TTFB+timings.Transfer {
+		t.Errorf("duration %v should include ttfb and transfer %+v", result.Duration, timings)
+	}
+}
+
+func TestKeepAlive(t *testing.T) {
+	var conns atomic.Int32
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	tests := []struct {
+		name       string
+		connection ConnectionConfig
+		wantReused []bool
+		wantConns  int32
+	}{
+		{
+			name:       "disabled",
+			wantReused: []bool{false, false, false},
+			wantConns:  3,
+		},
+		{
+			name:       "enabled",
+			connection: ConnectionConfig{KeepAlive: true, IdleTimeout: time.Minute, MaxIdle: 1},
+			wantReused: []bool{false, true, true},
+			wantConns:  1,
+		},
+		{
+			name:       "idle timeout",
+			connection: ConnectionConfig{KeepAlive: true, IdleTimeout: time.Nanosecond},
+			wantReused: []bool{false, false, false},
+			wantConns:  3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conns.Store(0)
+			config := &Config{
+				URL:        server.URL,
+				Timeout:    TimeoutConfig{Connect: time.Second, Read: time.Second},
+				Connection: tt.connection,
+				Asserts:    AssertsConfig{StatusCode: StatusCodeAssert{Values: []int{200}}},
+			}
+			client, err := newClient(config, nil)
+			if err != nil {
+				t.Fatalf("newClient() error = %v", err)
+			}
+			defer client.CloseIdleConnections()
+
+			for i, want := range tt.wantReused {
+				if tt.connection.IdleTimeout == time.Nanosecond {
+					time.Sleep(10 * time.Millisecond)
+				}
+				result, err := checkOnce(context.Background(), config, client)
+				if err != nil {
+					t.Fatalf("checkOnce() error = %v", err)
+				}
+				if !result.Success() {
+					t.Fatalf("statusCode = %d (err = %v)", result.StatusCode, result.Err)
+				}
+				if result.Reused != want {
+					t.Errorf("check %d: reused = %v, want %v", i, result.Reused, want)
+				}
+				if result.RemoteIP != "127.0.0.1" {
+					t.Errorf("check %d: remoteIP = %q", i, result.RemoteIP)
+				}
+				if result.Reused && (result.Timings.Connect != 0 || result.Timings.TTFB == 0) {
+					t.Errorf("check %d: timings = %+v", i, result.Timings)
+				}
+			}
+			if got := conns.Load(); got != tt.wantConns {
+				t.Errorf("connections = %d, want %d", got, tt.wantConns)
+			}
+		})
+	}
+}
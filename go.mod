@@ -1,5 +1,5 @@
 module github.com/yktakaha4/chechekule
 
-go 1.22
+go 1.24
 
 require gopkg.in/yaml.v3 v3.0.1
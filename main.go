@@ -2,17 +2,17 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"net/http"
-	"net/http/cookiejar"
+	"log"
 	"os"
-	"os/exec"
-	"regexp"
-	"strconv"
-	"strings"
+	"os/signal"
+	"syscall"
 	"time"
+
+	"github.com/yktakaha4/chechekule/checker"
 )
 
 // バージョン情報
@@ -20,29 +20,25 @@ var (
 	Version = "dev" // ビルド時に上書きされます
 )
 
-// エラーコードの定義
+// 終了コード
 const (
-	StatusDNSLookupFailed  = -1
-	StatusConnectionFailed = -2
-	StatusTimeout          = -3
-	StatusRedirectLoop     = -4
-	StatusAssertFailed     = -5
-	StatusUnknown          = -999
+	exitNotPassed = 1 // count・duration・until の条件を満たさなかった
+	exitError     = 2 // 設定や実行時のエラー
 )
 
-// エラーメッセージの定義
-var errorMessages = map[int]string{
-	StatusDNSLookupFailed:  "DNS_LOOKUP_FAILED",
-	StatusConnectionFailed: "CONNECTION_FAILED",
-	StatusTimeout:          "TIMEOUT",
-	StatusRedirectLoop:     "REDIRECT_LOOP_DETECTED",
-	StatusAssertFailed:     "ASSERT_FAILED",
-	StatusUnknown:          "UNKNOWN_ERROR",
-}
-
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "test-notify" {
+		runTestNotify(os.Args[2:])
+		return
+	}
+
 	configPath := flag.String("c", "", "config file path")
 	version := flag.Bool("version", false, "show version")
+	timings := flag.Bool("timings", false, "print per-phase timings on stdout")
+	count := flag.Int("count", 0, "stop after N checks")
+	duration := flag.Duration("duration", 0, "stop after the given wall-clock duration")
+	until := flag.String("until", "", "stop on the first matching result (success or failure)")
+	untilConsecutive := flag.Int("until-consecutive", 0, "stop after N matching results in a row")
 	flag.Parse()
 
 	if *version {
@@ -50,188 +46,128 @@ func main() {
 		os.Exit(0)
 	}
 
-	var config *Config
+	var config *checker.Config
 	var err error
 
 	if *configPath != "" {
-		config, err = LoadConfig(*configPath)
+		config, err = checker.LoadConfig(*configPath)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
-			os.Exit(1)
+			os.Exit(exitError)
 		}
 	} else {
 		args := flag.Args()
 		if len(args) != 1 {
-			fmt.Fprintf(os.Stderr, "Usage: %s [-c config-file] [-version] <url>\n", os.Args[0])
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "Usage: %s [-c config-file] [-timings] [-count N] [-duration D] [-until success|failure [-until-consecutive N]] [-version] <url>\n", os.Args[0])
+			os.Exit(exitError)
 		}
-		config = &Config{
+		config = &checker.Config{
 			URL:      args[0],
 			Interval: time.Second,
-			Timeout: TimeoutConfig{
-				Connect: 3 * time.Second,
-				Read:    7 * time.Second,
+			Timeout: checker.TimeoutConfig{
+				Connect:      3 * time.Second,
+				TLSHandshake: 3 * time.Second,
+				Read:         7 * time.Second,
 			},
 		}
 	}
 
-	if err := runCheck(config, nil); err != nil {
-		fmt.Fprintf(os.Stderr, "Error during execution: %v\n", err)
-		os.Exit(1)
+	// コマンドラインの指定は設定ファイルの値より優先します
+	for _, target := range config.CheckTargets() {
+		if *timings {
+			target.Output.Timings = true
+		}
+		if *count > 0 {
+			target.Count = *count
+		}
+		if *duration > 0 {
+			target.Duration = *duration
+		}
+		if *until != "" {
+			target.Until = *until
+		}
+		if *untilConsecutive > 0 {
+			target.UntilConsecutive = *untilConsecutive
+		}
+		if err := target.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid options: %v\n", err)
+			os.Exit(exitError)
+		}
 	}
-}
 
-func getErrorStatus(err error) int {
-	if err == nil {
-		return 0
+	// SIGINT/SIGTERM でループを止めて統計を出力します。
+	// フックや通知の終了を待っている間に2回目のシグナルを受けた場合はすぐに終了できるよう、1回目で通常の動作に戻します
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	context.AfterFunc(ctx, stop)
+
+	if err := runCheck(ctx, config, os.Stdout, os.Stderr); err != nil {
+		var notPassed *checker.NotPassedError
+		if errors.As(err, &notPassed) {
+			fmt.Fprintln(os.Stderr, notPassed.Error())
+		} else {
+			fmt.Fprintf(os.Stderr, "Error during execution: %v\n", err)
+		}
+		os.Exit(exitCode(err))
 	}
+}
 
-	errStr := err.Error()
+// exitCode はチェックが条件を満たさなかった場合と、それ以外のエラーを区別できる終了コードを返します
+func exitCode(err error) int {
+	var notPassed *checker.NotPassedError
 	switch {
-	case strings.Contains(errStr, "no such host"):
-		return StatusDNSLookupFailed
-	case strings.Contains(errStr, "connection refused"):
-		return StatusConnectionFailed
-	case strings.Contains(errStr, "timeout") || strings.Contains(errStr, "deadline exceeded"):
-		return StatusTimeout
-	case strings.Contains(errStr, "stopped after") && strings.Contains(errStr, "redirects"):
-		return StatusRedirectLoop
+	case err == nil:
+		return 0
+	case errors.As(err, &notPassed):
+		return exitNotPassed
 	default:
-		return StatusUnknown
+		return exitError
 	}
 }
 
-func validateResponse(config *Config, resp *http.Response, body []byte) error {
-	// ステータスコードの検証
-	if len(config.Asserts.StatusCode.Values) > 0 {
-		found := false
-		for _, code := range config.Asserts.StatusCode.Values {
-			if resp.StatusCode == code {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return fmt.Errorf("status code %d not in expected values %v", resp.StatusCode, config.Asserts.StatusCode.Values)
-		}
+// runCheck は結果の行・ログファイル・フック・統計の各 Sink を組み合わせて、全ターゲットのチェックを実行します。
+// count・duration・until で区切られたターゲットが条件を満たさずに終了した場合は *checker.NotPassedError を返します
+func runCheck(ctx context.Context, config *checker.Config, stdout, stderr io.Writer) error {
+	hooks := checker.NewHookSink(config, stderr)
+	summary := checker.NewSummary(config)
+	c, err := checker.New(config, checker.NewOutputSink(stdout, stderr), checker.NewLogSink(stderr), hooks, summary)
+	if err != nil {
+		return err
 	}
+	c.ErrorLog = log.New(stderr, "", 0)
 
-	if config.Asserts.StatusCode.Regex != "" {
-		re, err := regexp.Compile(config.Asserts.StatusCode.Regex)
-		if err != nil {
-			return fmt.Errorf("invalid status code regex: %w", err)
-		}
-		if !re.MatchString(strconv.Itoa(resp.StatusCode)) {
-			return fmt.Errorf("status code %d does not match regex %s", resp.StatusCode, config.Asserts.StatusCode.Regex)
-		}
-	}
+	hooks.Start()
+	err = c.Run(ctx)
+	hooks.Close()
 
-	// レスポンスボディの検証
-	if config.Asserts.Body.Regex != "" {
-		re, err := regexp.Compile(config.Asserts.Body.Regex)
-		if err != nil {
-			return fmt.Errorf("invalid body regex: %w", err)
-		}
-		if !re.Match(body) {
-			return fmt.Errorf("body does not match regex %s", config.Asserts.Body.Regex)
-		}
+	// JSON Lines を標準出力に流している場合は混ざらないよう標準エラー出力に書き出します
+	if config.Output.Format == checker.OutputFormatJSONL {
+		summary.Print(stderr)
+	} else {
+		summary.Print(stdout)
 	}
-
-	return nil
+	return err
 }
 
-func runCheck(config *Config, done <-chan bool) error {
-	// Execute hook if configured
-	if config.Hooks.OnStart != "" {
-		cmd := exec.Command(config.Hooks.OnStart)
-		if err := cmd.Run(); err != nil {
-			fmt.Fprintf(os.Stderr, "Failed to execute hook: %v\n", err)
-		}
-	}
+// runTestNotify は test-notify サブコマンドとして、設定された通知先にサンプルを送信します
+func runTestNotify(args []string) {
+	flags := flag.NewFlagSet("test-notify", flag.ExitOnError)
+	configPath := flags.String("c", "", "config file path")
+	flags.Parse(args)
 
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		return fmt.Errorf("failed to create cookie jar: %w", err)
+	if *configPath == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s test-notify -c config-file\n", os.Args[0])
+		os.Exit(exitError)
 	}
 
-	client := &http.Client{
-		Jar: jar,
-		Transport: &http.Transport{
-			DisableKeepAlives: true,
-		},
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			if !config.FollowRedirects.Enabled {
-				return http.ErrUseLastResponse
-			}
-			if len(via) >= config.FollowRedirects.MaxCount {
-				return fmt.Errorf("stopped after %d redirects", config.FollowRedirects.MaxCount)
-			}
-			return nil
-		},
-	}
-
-	if err := config.SetupCookies(jar); err != nil {
-		return fmt.Errorf("failed to setup cookies: %w", err)
+	config, err := checker.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(exitError)
 	}
 
-	ticker := time.NewTicker(config.Interval)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-done:
-			return nil
-		case <-ticker.C:
-			requestedAt := time.Now()
-			start := time.Now()
-
-			req, err := http.NewRequest("GET", config.URL, nil)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "Failed to create request: %v\n", err)
-				continue
-			}
-
-			ctx := req.Context()
-			ctx, cancel := context.WithTimeout(ctx, config.Timeout.Connect+config.Timeout.Read)
-			req = req.WithContext(ctx)
-			defer cancel()
-
-			resp, err := client.Do(req)
-			duration := time.Since(start)
-
-			var statusCode int
-			var body []byte
-			if err != nil {
-				statusCode = getErrorStatus(err)
-				fmt.Printf("%s\t%s\t%v\n", requestedAt.Format("2006-01-02T15:04:05.000Z07:00"), errorMessages[statusCode], duration)
-			} else {
-				body, err = io.ReadAll(resp.Body)
-				resp.Body.Close()
-				if err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to read response body: %v\n", err)
-					continue
-				}
-
-				if err := validateResponse(config, resp, body); err != nil {
-					statusCode = StatusAssertFailed
-					fmt.Printf("%s\t%s\t%v\n", requestedAt.Format("2006-01-02T15:04:05.000Z07:00"), errorMessages[statusCode], duration)
-					fmt.Fprintf(os.Stderr, "Assert failed: %v\n", err)
-					fmt.Fprintf(os.Stderr, "Response Headers:\n")
-					for k, v := range resp.Header {
-						fmt.Fprintf(os.Stderr, "  %s: %v\n", k, v)
-					}
-					fmt.Fprintf(os.Stderr, "Response Body:\n%s\n", string(body))
-				} else {
-					statusCode = resp.StatusCode
-					fmt.Printf("%s\t%d\t%v\n", requestedAt.Format("2006-01-02T15:04:05.000Z07:00"), statusCode, duration)
-				}
-			}
-
-			if config.Log != nil {
-				if err := config.WriteLog(requestedAt, statusCode, duration); err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to write log: %v\n", err)
-				}
-			}
-		}
+	if err := checker.SendTestNotifications(config, os.Stdout, os.Stderr); err != nil {
+		fmt.Fprintf(os.Stderr, "Error during execution: %v\n", err)
+		os.Exit(exitError)
 	}
 }
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -9,522 +12,146 @@ import (
 	"strings"
 	"testing"
 	"time"
-)
-
-func TestBasicRequest(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer server.Close()
-
-	config := &Config{
-		URL:      server.URL,
-		Interval: 100 * time.Millisecond,
-		Timeout: TimeoutConfig{
-			Connect: 1 * time.Second,
-			Read:    1 * time.Second,
-		},
-	}
 
-	done := make(chan bool)
-	go func() {
-		time.Sleep(250 * time.Millisecond) // Wait for 2-3 requests
-		done <- true
-	}()
-
-	if err := runCheck(config, done); err != nil {
-		t.Errorf("Expected no error, got %v", err)
-	}
-}
-
-func TestTimeout(t *testing.T) {
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		time.Sleep(2 * time.Second)
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer server.Close()
+	"github.com/yktakaha4/chechekule/checker"
+)
 
-	config := &Config{
-		URL:      server.URL,
-		Interval: 100 * time.Millisecond,
-		Timeout: TimeoutConfig{
-			Connect: 100 * time.Millisecond,
-			Read:    100 * time.Millisecond,
-		},
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "passed", err: nil, want: 0},
+		{name: "not passed", err: &checker.NotPassedError{Targets: []string{"api"}}, want: exitNotPassed},
+		{name: "wrapped not passed", err: fmt.Errorf("run: %w", &checker.NotPassedError{Targets: []string{"api"}}), want: exitNotPassed},
+		{name: "runtime error", err: errors.New("listen tcp: address already in use"), want: exitError},
 	}
 
-	done := make(chan bool)
-	go func() {
-		time.Sleep(250 * time.Millisecond)
-		done <- true
-	}()
-
-	if err := runCheck(config, done); err != nil {
-		t.Errorf("Expected no error, got %v", err)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
 	}
 }
 
-func TestCookies(t *testing.T) {
-	expectedCookie := "test-cookie"
+func TestRunCheck(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		cookie := r.Header.Get("Cookie")
-		if !strings.Contains(cookie, expectedCookie) {
-			t.Errorf("Expected cookie %s, got %s", expectedCookie, cookie)
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
 		}
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer server.Close()
 
-	config := &Config{
-		URL:      server.URL,
-		Interval: 100 * time.Millisecond,
-		Timeout: TimeoutConfig{
-			Connect: 1 * time.Second,
-			Read:    1 * time.Second,
-		},
-		Cookies: []CookieConfig{
-			{
-				Key:   "session",
-				Value: expectedCookie,
-			},
-		},
-	}
-
-	done := make(chan bool)
-	go func() {
-		time.Sleep(250 * time.Millisecond)
-		done <- true
-	}()
-
-	if err := runCheck(config, done); err != nil {
-		t.Errorf("Expected no error, got %v", err)
-	}
-}
-
-func TestLogging(t *testing.T) {
-	tempDir := t.TempDir()
-	logPath := filepath.Join(tempDir, "test.log")
-
-	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-	}))
-	defer server.Close()
-
-	config := &Config{
-		URL:      server.URL,
-		Interval: 100 * time.Millisecond,
-		Timeout: TimeoutConfig{
-			Connect: 1 * time.Second,
-			Read:    1 * time.Second,
-		},
-		Log: &LogConfig{
-			Path:   logPath,
-			Format: "{{.statusCode}}",
-		},
-	}
-
-	done := make(chan bool)
-	go func() {
-		time.Sleep(250 * time.Millisecond)
-		done <- true
-	}()
-
-	if err := runCheck(config, done); err != nil {
-		t.Errorf("Expected no error, got %v", err)
-	}
-
-	// Check if log file exists and contains correct status code
-	content, err := os.ReadFile(logPath)
-	if err != nil {
-		t.Errorf("Failed to read log file: %v", err)
-	}
-
-	if !strings.Contains(string(content), "200") {
-		t.Errorf("Expected log to contain status code 200, got %s", string(content))
-	}
-}
-
-func TestConfigValidation(t *testing.T) {
-	tempFile := filepath.Join(t.TempDir(), "config.yaml")
-	content := []byte(`url: http://example.com
-interval: 1s
-timeout:
-  connect: 3s
-  read: 7s`)
-
-	if err := os.WriteFile(tempFile, content, 0644); err != nil {
-		t.Fatalf("Failed to write config file: %v", err)
-	}
-
-	config, err := LoadConfig(tempFile)
-	if err != nil {
-		t.Errorf("Expected no error, got %v", err)
-	}
-
-	if config.URL != "http://example.com" {
-		t.Errorf("Expected URL http://example.com, got %s", config.URL)
-	}
-
-	if config.Interval != time.Second {
-		t.Errorf("Expected interval 1s, got %v", config.Interval)
-	}
-
-	if config.Timeout.Connect != 3*time.Second {
-		t.Errorf("Expected connect timeout 3s, got %v", config.Timeout.Connect)
-	}
-
-	if config.Timeout.Read != 7*time.Second {
-		t.Errorf("Expected read timeout 7s, got %v", config.Timeout.Read)
-	}
-}
-
-func TestErrorStatus(t *testing.T) {
 	tests := []struct {
-		name     string
-		err      error
-		expected int
+		name          string
+		path          string
+		format        string
+		wantStdout    []string
+		wantStderr    []string
+		wantNotPassed bool
 	}{
 		{
-			name:     "DNS lookup failed",
-			err:      fmt.Errorf("dial tcp: lookup example.com: no such host"),
-			expected: StatusDNSLookupFailed,
+			name:       "text",
+			path:       "/up",
+			wantStdout: []string{"\tapi\t200\t", "--- api statistics ---", "2 checks, 2 succeeded, 0 failed"},
 		},
 		{
-			name:     "Connection refused",
-			err:      fmt.Errorf("dial tcp 127.0.0.1:8080: connect: connection refused"),
-			expected: StatusConnectionFailed,
+			name:          "assert failure",
+			path:          "/down",
+			wantStdout:    []string{"\tapi\tASSERT_FAILED\t", "2 checks, 0 succeeded, 2 failed"},
+			wantStderr:    []string{"[api] Assert failed: status code 503 not in expected values [200]"},
+			wantNotPassed: true,
 		},
 		{
-			name:     "Timeout",
-			err:      fmt.Errorf("context deadline exceeded"),
-			expected: StatusTimeout,
-		},
-		{
-			name:     "Unknown error",
-			err:      fmt.Errorf("some other error"),
-			expected: StatusUnknown,
-		},
-		{
-			name:     "No error",
-			err:      nil,
-			expected: 0,
+			name:       "jsonl",
+			path:       "/up",
+			format:     checker.OutputFormatJSONL,
+			wantStdout: []string{`"target":"api","status_code":200`},
+			// 統計は JSON Lines と混ざらないよう標準エラー出力に書き出すこと
+			wantStderr: []string{"--- api statistics ---"},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := getErrorStatus(tt.err)
-			if got != tt.expected {
-				t.Errorf("getErrorStatus() = %v, want %v", got, tt.expected)
+			config := checker.DefaultConfig()
+			config.Name = "api"
+			config.URL = server.URL + tt.path
+			config.Interval = 10 * time.Millisecond
+			config.Count = 2
+			config.Output.Format = tt.format
+
+			var stdout, stderr bytes.Buffer
+			err := runCheck(context.Background(), config, &stdout, &stderr)
+
+			var notPassed *checker.NotPassedError
+			if gotNotPassed := errors.As(err, &notPassed); gotNotPassed != tt.wantNotPassed {
+				t.Errorf("runCheck() error = %v, want not passed error %v", err, tt.wantNotPassed)
 			}
-		})
-	}
-}
-
-func TestFollowRedirect(t *testing.T) {
-	tests := []struct {
-		name            string
-		followRedirects FollowRedirectsConfig
-		expectedCode    int
-		setupServers    func() ([]*httptest.Server, string)
-	}{
-		{
-			name: "follow redirects",
-			followRedirects: FollowRedirectsConfig{
-				Enabled:  true,
-				MaxCount: 10,
-			},
-			expectedCode: http.StatusOK,
-			setupServers: func() ([]*httptest.Server, string) {
-				targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					w.WriteHeader(http.StatusOK)
-				}))
-				redirectServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					http.Redirect(w, r, targetServer.URL, http.StatusFound)
-				}))
-				return []*httptest.Server{targetServer, redirectServer}, redirectServer.URL
-			},
-		},
-		{
-			name: "do not follow redirects",
-			followRedirects: FollowRedirectsConfig{
-				Enabled:  false,
-				MaxCount: 10,
-			},
-			expectedCode: http.StatusFound,
-			setupServers: func() ([]*httptest.Server, string) {
-				targetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					w.WriteHeader(http.StatusOK)
-				}))
-				redirectServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					http.Redirect(w, r, targetServer.URL, http.StatusFound)
-				}))
-				return []*httptest.Server{targetServer, redirectServer}, redirectServer.URL
-			},
-		},
-		{
-			name: "follow recursive redirects",
-			followRedirects: FollowRedirectsConfig{
-				Enabled:  true,
-				MaxCount: 10,
-			},
-			expectedCode: http.StatusOK,
-			setupServers: func() ([]*httptest.Server, string) {
-				// Create 3 servers with circular redirects
-				servers := make([]*httptest.Server, 3)
-				for i := range servers {
-					i := i // Capture loop variable
-					servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						if i == 2 {
-							w.WriteHeader(http.StatusOK)
-							return
-						}
-						http.Redirect(w, r, servers[i+1].URL, http.StatusFound)
-					}))
-				}
-				return servers, servers[0].URL
-			},
-		},
-		{
-			name: "too many redirects",
-			followRedirects: FollowRedirectsConfig{
-				Enabled:  true,
-				MaxCount: 5,
-			},
-			expectedCode: StatusRedirectLoop,
-			setupServers: func() ([]*httptest.Server, string) {
-				// Create 6 servers with circular redirects
-				servers := make([]*httptest.Server, 6)
-				for i := range servers {
-					i := i // Capture loop variable
-					servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-						if i == len(servers)-1 {
-							w.WriteHeader(http.StatusOK)
-							return
-						}
-						http.Redirect(w, r, servers[i+1].URL, http.StatusFound)
-					}))
+			for _, want := range tt.wantStdout {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout = %q, want to contain %q", stdout.String(), want)
 				}
-				return servers, servers[0].URL
-			},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			servers, startURL := tt.setupServers()
-			defer func() {
-				for _, server := range servers {
-					server.Close()
-				}
-			}()
-
-			config := &Config{
-				URL:             startURL,
-				Interval:        100 * time.Millisecond,
-				FollowRedirects: tt.followRedirects,
-				Timeout: TimeoutConfig{
-					Connect: 1 * time.Second,
-					Read:    1 * time.Second,
-				},
 			}
-
-			done := make(chan bool)
-			go func() {
-				time.Sleep(250 * time.Millisecond)
-				done <- true
-			}()
-
-			if err := runCheck(config, done); err != nil {
-				t.Errorf("Expected no error, got %v", err)
-			}
-		})
-	}
-}
-
-func TestAsserts(t *testing.T) {
-	tests := []struct {
-		name        string
-		config      *Config
-		handler     http.HandlerFunc
-		expectError bool
-	}{
-		{
-			name: "status code match",
-			config: &Config{
-				Asserts: AssertsConfig{
-					StatusCode: StatusCodeAssert{
-						Values: []int{200, 201},
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusOK)
-			},
-			expectError: false,
-		},
-		{
-			name: "status code mismatch",
-			config: &Config{
-				Asserts: AssertsConfig{
-					StatusCode: StatusCodeAssert{
-						Values: []int{200, 201},
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusNotFound)
-			},
-			expectError: true,
-		},
-		{
-			name: "status code regex match",
-			config: &Config{
-				Asserts: AssertsConfig{
-					StatusCode: StatusCodeAssert{
-						Regex: "^2..$",
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusOK)
-			},
-			expectError: false,
-		},
-		{
-			name: "status code regex mismatch",
-			config: &Config{
-				Asserts: AssertsConfig{
-					StatusCode: StatusCodeAssert{
-						Regex: "^2..$",
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusNotFound)
-			},
-			expectError: true,
-		},
-		{
-			name: "body regex match",
-			config: &Config{
-				Asserts: AssertsConfig{
-					Body: BodyAssert{
-						Regex: "success",
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("operation success"))
-			},
-			expectError: false,
-		},
-		{
-			name: "body regex mismatch",
-			config: &Config{
-				Asserts: AssertsConfig{
-					Body: BodyAssert{
-						Regex: "success",
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("operation failed"))
-			},
-			expectError: true,
-		},
-		{
-			name: "multiple asserts",
-			config: &Config{
-				Asserts: AssertsConfig{
-					StatusCode: StatusCodeAssert{
-						Values: []int{200},
-					},
-					Body: BodyAssert{
-						Regex: "success",
-					},
-				},
-			},
-			handler: func(w http.ResponseWriter, r *http.Request) {
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte("operation success"))
-			},
-			expectError: false,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			server := httptest.NewServer(tt.handler)
-			defer server.Close()
-
-			tt.config.URL = server.URL
-			tt.config.Interval = 100 * time.Millisecond
-			tt.config.Timeout = TimeoutConfig{
-				Connect: 1 * time.Second,
-				Read:    1 * time.Second,
+			for _, want := range tt.wantStderr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("stderr = %q, want to contain %q", stderr.String(), want)
+				}
 			}
-
-			done := make(chan bool)
-			go func() {
-				time.Sleep(250 * time.Millisecond)
-				done <- true
-			}()
-
-			if err := runCheck(tt.config, done); err != nil {
-				t.Errorf("Expected no error, got %v", err)
+			if tt.format == checker.OutputFormatJSONL && strings.Contains(stdout.String(), "statistics") {
+				t.Errorf("stdout = %q, want only JSON Lines", stdout.String())
 			}
 		})
 	}
 }
 
-func TestHooks(t *testing.T) {
-	// Create a temporary script file for testing
-	tmpDir := t.TempDir()
-	scriptPath := filepath.Join(tmpDir, "test_hook.sh")
-	scriptContent := `#!/bin/sh
-echo "hook executed" > "` + filepath.Join(tmpDir, "hook_output.txt") + `"
-`
-	if err := os.WriteFile(scriptPath, []byte(scriptContent), 0755); err != nil {
-		t.Fatalf("Failed to write test script: %v", err)
-	}
-
+func TestRunCheckLogAndHooks(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}))
 	defer server.Close()
 
-	config := &Config{
-		URL:      server.URL,
-		Interval: 100 * time.Millisecond,
-		Timeout: TimeoutConfig{
-			Connect: 1 * time.Second,
-			Read:    1 * time.Second,
-		},
-		Hooks: HooksConfig{
-			OnStart: scriptPath,
-		},
+	tmpDir := t.TempDir()
+	scriptPath := filepath.Join(tmpDir, "hook.sh")
+	script := `#!/bin/sh
+echo "$CHECHEKULE_EVENT" >> "` + tmpDir + `/events.log"
+`
+	if err := os.WriteFile(scriptPath, []byte(script), 0755); err != nil {
+		t.Fatalf("Failed to write hook script: %v", err)
 	}
 
-	done := make(chan bool)
-	go func() {
-		time.Sleep(250 * time.Millisecond)
-		done <- true
-	}()
+	config := checker.DefaultConfig()
+	config.URL = server.URL
+	config.Interval = 10 * time.Millisecond
+	config.Count = 2
+	config.Log = &checker.LogConfig{Path: filepath.Join(tmpDir, "check.log"), Format: "{{.statusCode}}"}
+	config.Hooks.OnStart = scriptPath
+	config.Hooks.OnEach = scriptPath
+	config.Hooks.OnStop = scriptPath
 
-	if err := runCheck(config, done); err != nil {
-		t.Errorf("Expected no error, got %v", err)
+	// CLI ではログファイルとフックにも結果を渡すこと
+	var stdout, stderr bytes.Buffer
+	if err := runCheck(context.Background(), config, &stdout, &stderr); err != nil {
+		t.Fatalf("runCheck() error = %v, stderr = %q", err, stderr.String())
 	}
 
-	// Verify that the hook was executed
-	outputPath := filepath.Join(tmpDir, "hook_output.txt")
-	content, err := os.ReadFile(outputPath)
+	content, err := os.ReadFile(config.Log.Path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	if string(content) != "200\n200\n" {
+		t.Errorf("log = %q, want two 200 lines", content)
+	}
+	events, err := os.ReadFile(filepath.Join(tmpDir, "events.log"))
 	if err != nil {
-		t.Errorf("Failed to read hook output: %v", err)
+		t.Fatalf("hooks were not executed: %v", err)
 	}
-	if string(content) != "hook executed\n" {
-		t.Errorf("Expected hook output 'hook executed', got %s", string(content))
+	if string(events) != "\neach\neach\nstop\n" {
+		t.Errorf("events = %q, want on_start, two on_each and on_stop", events)
 	}
 }